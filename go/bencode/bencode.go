@@ -0,0 +1,464 @@
+// Package bencode implements the bencoding described in BEP 3.
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// scanner reads either from an in-memory input string or, if reader is set, from a stream. position counts the bytes
+// consumed so far in both cases.
+type scanner struct {
+	input    string
+	position int
+	reader   *bufio.Reader
+	readErr  error
+	options  DecodeOptions
+	depth    int
+	elements int
+}
+
+func (c *scanner) next() (result byte, eof bool) {
+	if c.reader != nil {
+		result, err := c.reader.ReadByte()
+		if err != nil {
+			c.readErr = err
+			return 0, true
+		}
+
+		c.position++
+		return result, false
+	}
+
+	if c.position >= len(c.input) {
+		return 0, true
+	}
+
+	result = c.input[c.position]
+	c.position++
+
+	return result, false
+}
+
+func (c *scanner) peek() (result byte, eof bool) {
+	if c.reader != nil {
+		b, err := c.reader.Peek(1)
+		if err != nil {
+			c.readErr = err
+			return 0, true
+		}
+
+		return b[0], false
+	}
+
+	if c.position >= len(c.input) {
+		return 0, true
+	}
+
+	return c.input[c.position], false
+}
+
+func (c *scanner) acceptSingle(accepted string) (result byte, found bool) {
+	if b, eof := c.peek(); eof || !bytes.Contains([]byte(accepted), []byte{b}) {
+		return 0, false
+	} else {
+		c.next()
+		return b, true
+	}
+}
+
+func (c *scanner) acceptMultiple(accepted string) string {
+	if c.reader != nil {
+		var builder strings.Builder
+		for b, found := c.acceptSingle(accepted); found; b, found = c.acceptSingle(accepted) {
+			builder.WriteByte(b)
+		}
+		return builder.String()
+	}
+
+	var length = 0
+
+	for _, found := c.acceptSingle(accepted); found; _, found = c.acceptSingle(accepted) {
+		length++
+	}
+
+	return c.input[c.position-length : c.position]
+}
+
+func (c *scanner) acceptRun(length int) (string, error) {
+	if c.reader != nil {
+		// Copy incrementally instead of allocating length bytes up front, so a bogus length in a short stream does not
+		// allocate a huge buffer.
+		var builder strings.Builder
+		n, err := io.CopyN(&builder, c.reader, int64(length))
+		c.position += int(n)
+		if err != nil {
+			c.readErr = err
+			return "", fmt.Errorf("unexpected end of input")
+		}
+
+		return builder.String(), nil
+	}
+
+	if c.position+length > len(c.input) {
+		return "", fmt.Errorf("unexpected end of input")
+	}
+
+	var result = c.input[c.position : c.position+length]
+	c.position += length
+
+	return result, nil
+}
+
+type Int int64
+type String string
+type List []Value
+type Dict map[string]Value
+
+// BigInt holds integers that do not fit into an Int. The decoder only returns it if DecodeOptions.BigIntegers is set.
+type BigInt struct {
+	*big.Int
+}
+
+// Value is any of Int, String, List or Dict, or BigInt if enabled.
+type Value interface {
+	Encode() string
+	String() string
+	appendTo(dst []byte) []byte
+}
+
+// encodeBuffers are reused by encodeToString, which copies the result into a string anyway. Buffers that grew for
+// unusually large values are not kept.
+var encodeBuffers = sync.Pool{New: func() any { return new([]byte) }}
+
+const maxPooledBuffer = 64 << 10
+
+func encodeToString(v Value) string {
+	var buffer = encodeBuffers.Get().(*[]byte)
+	*buffer = Append((*buffer)[:0], v)
+	var result = string(*buffer)
+	if cap(*buffer) <= maxPooledBuffer {
+		encodeBuffers.Put(buffer)
+	}
+	return result
+}
+
+func (i Int) Encode() string {
+	return encodeToString(i)
+}
+
+func (i Int) appendTo(dst []byte) []byte {
+	return AppendInt(dst, int64(i))
+}
+
+func (i Int) String() string {
+	return fmt.Sprintf("%d", i)
+}
+
+func (i BigInt) Encode() string {
+	return encodeToString(i)
+}
+
+func (i BigInt) appendTo(dst []byte) []byte {
+	dst = append(dst, 'i')
+	dst = i.Int.Append(dst, 10)
+	return append(dst, 'e')
+}
+
+func (s String) Encode() string {
+	return encodeToString(s)
+}
+
+func (s String) appendTo(dst []byte) []byte {
+	return AppendString(dst, string(s))
+}
+
+func (s String) String() string {
+	return string(s)
+}
+
+func (l List) Encode() string {
+	return encodeToString(l)
+}
+
+func (l List) appendTo(dst []byte) []byte {
+	dst = append(dst, 'l')
+
+	for _, v := range l {
+		dst = v.appendTo(dst)
+	}
+
+	return append(dst, 'e')
+}
+
+func (l List) String() string {
+	var buffer strings.Builder
+	buffer.WriteString("[")
+	for i, v := range l {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(v.String())
+	}
+	buffer.WriteString("]")
+	return buffer.String()
+}
+
+func (d Dict) Encode() string {
+	return encodeToString(d)
+}
+
+func (d Dict) appendTo(dst []byte) []byte {
+	dst = append(dst, 'd')
+
+	// KRPC dicts are small, so sorting the keys in a fixed size array avoids allocating in the common case.
+	var keyArray [16]string
+	var keys = keyArray[:0]
+	for k := range d {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		dst = AppendString(dst, k)
+		dst = d[k].appendTo(dst)
+	}
+
+	return append(dst, 'e')
+}
+
+func (d Dict) String() string {
+	var buffer strings.Builder
+	buffer.WriteString("{ ")
+	var keys = make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(String(k).String())
+		buffer.WriteString(": ")
+		buffer.WriteString(d[k].String())
+	}
+	buffer.WriteString(" }")
+	return buffer.String()
+}
+
+// scanInteger reads an optionally signed run of digits, returning it with the sign if there is one.
+func scanInteger(scanner *scanner) (string, error) {
+	var start = scanner.position
+	var signChar, hasSign = scanner.acceptSingle("-+")
+
+	var chars = scanner.acceptMultiple("0123456789")
+
+	if len(chars) == 0 {
+		return "", fmt.Errorf("unexpected integer with zero digits")
+	}
+
+	if scanner.options.Strict {
+		if hasSign && signChar == '+' {
+			return "", fmt.Errorf("non-canonical integer at offset %d: explicit \"+\" sign", start)
+		} else if len(chars) > 1 && chars[0] == '0' {
+			return "", fmt.Errorf("non-canonical integer at offset %d: leading zero", start)
+		} else if signChar == '-' && chars == "0" {
+			return "", fmt.Errorf("non-canonical integer at offset %d: negative zero", start)
+		}
+	}
+
+	if hasSign {
+		return string(signChar) + chars, nil
+	}
+	return chars, nil
+}
+
+func parseInteger(scanner *scanner) (int64, error) {
+	var start = scanner.position
+	var chars, err = scanInteger(scanner)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := strconv.ParseInt(chars, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("integer %s at offset %d overflows int64", chars, start)
+	}
+	return result, err
+}
+
+// decodeInteger returns an Int, or a BigInt for integers beyond the range of int64 if the BigIntegers option is set.
+func decodeInteger(scanner *scanner) (Value, error) {
+	if c, found := scanner.acceptSingle("i"); !found {
+		return nil, fmt.Errorf("expected integer to start with \"i\", got %c instead", c)
+	}
+
+	var start = scanner.position
+	var chars, err = scanInteger(scanner)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, found := scanner.acceptSingle("e"); !found {
+		return nil, fmt.Errorf("unexpected end of integer: %c", c)
+	}
+
+	result, err := strconv.ParseInt(chars, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if !scanner.options.BigIntegers {
+			return nil, fmt.Errorf("integer %s at offset %d overflows int64", chars, start)
+		}
+
+		var value, _ = new(big.Int).SetString(chars, 10)
+		return BigInt{value}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return Int(result), nil
+}
+
+func decodeString(scanner *scanner) (String, error) {
+	if c, _ := scanner.peek(); scanner.options.Strict && (c == '-' || c == '+') {
+		return "", fmt.Errorf("non-canonical string length at offset %d: sign", scanner.position)
+	}
+
+	var start = scanner.position
+	var length, err = parseInteger(scanner)
+	if err != nil {
+		return "", err
+	} else if length < 0 {
+		return "", fmt.Errorf("unexpected negative string length")
+	} else if length > math.MaxInt {
+		return "", fmt.Errorf("string length %d is too large", length)
+	} else if err := scanner.checkStringLength(length, start); err != nil {
+		return "", err
+	}
+
+	if c, found := scanner.acceptSingle(":"); !found {
+		return "", fmt.Errorf("expected string to start with \":\", got %c instead", c)
+	}
+
+	result, err := scanner.acceptRun(int(length))
+	return String(result), err
+}
+
+func decodeList(scanner *scanner) (List, error) {
+	if c, found := scanner.acceptSingle("l"); !found {
+		return nil, fmt.Errorf("expected list to start with \"l\", got %c instead", c)
+	}
+
+	if err := scanner.enterContainer(); err != nil {
+		return nil, err
+	}
+	defer scanner.leaveContainer()
+
+	var result = make(List, 0)
+
+	for {
+		if _, found := scanner.acceptSingle("e"); found {
+			return result, nil
+		} else {
+			var value, err = decodeScannerValue(scanner)
+			if err != nil {
+				return nil, fmt.Errorf("decoding bencode list: %w", err)
+			}
+
+			result = append(result, value)
+		}
+	}
+}
+
+func decodeDict(scanner *scanner) (Dict, error) {
+	if c, found := scanner.acceptSingle("d"); !found {
+		return nil, fmt.Errorf("expected dict to start with \"d\", got %c instead", c)
+	}
+
+	if err := scanner.enterContainer(); err != nil {
+		return nil, err
+	}
+	defer scanner.leaveContainer()
+
+	var result = make(Dict)
+	var previousKey String
+	var isFirstKey = true
+
+	for {
+		if _, found := scanner.acceptSingle("e"); found {
+			return result, nil
+		} else {
+			var keyOffset = scanner.position
+			if err := scanner.countElement(); err != nil {
+				return nil, err
+			}
+
+			var key, err = decodeString(scanner)
+			if err != nil {
+				return nil, fmt.Errorf("decoding bencode dict key: %w", err)
+			}
+
+			if scanner.options.Strict && !isFirstKey {
+				if key == previousKey {
+					return nil, fmt.Errorf("duplicate dict key %q at offset %d", key, keyOffset)
+				} else if key < previousKey {
+					return nil, fmt.Errorf("dict key %q at offset %d is not sorted after %q", key, keyOffset, previousKey)
+				}
+			}
+			previousKey = key
+			isFirstKey = false
+
+			value, err := decodeScannerValue(scanner)
+			if err != nil {
+				return nil, fmt.Errorf("decoding bencode dict value: %w", err)
+			}
+
+			result[string(key)] = value
+		}
+	}
+}
+
+// DecodeDict decodes input, which must start with a bencoded dictionary. It decodes leniently, see DecodeOptions.
+func DecodeDict(input string) (Dict, error) {
+	return DecodeOptions{}.DecodeDict(input)
+}
+
+func decodeScannerValue(scanner *scanner) (Value, error) {
+	var c, eof = scanner.peek()
+	if eof {
+		return nil, fmt.Errorf("unexpected end of input")
+	}
+
+	if err := scanner.countElement(); err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case 'i':
+		return decodeInteger(scanner)
+	case 'l':
+		return decodeList(scanner)
+	case 'd':
+		return decodeDict(scanner)
+	default:
+		return decodeString(scanner)
+	}
+}
+
+// Decode decodes the bencoded value at the start of input. It decodes leniently, see DecodeOptions.
+func Decode(input string) (Value, error) {
+	return DecodeOptions{}.Decode(input)
+}
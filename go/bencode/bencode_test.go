@@ -1,17 +1,21 @@
-package main
+package bencode
 
-import "testing"
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
 
 func TestDecodeInteger(t *testing.T) {
 	var input = "i123e"
 	var result, err = decodeInteger(&scanner{input: input})
-	if result != 123 || err != nil {
+	if result != Int(123) || err != nil {
 		t.Error("expected -123, got", result, "err:", err)
 	}
 
 	input = "i-123e"
 	result, err = decodeInteger(&scanner{input: input})
-	if result != -123 || err != nil {
+	if result != Int(-123) || err != nil {
 		t.Error("expected -123, got", result, "err:", err)
 	}
 
@@ -71,11 +75,11 @@ func TestDecodeList(t *testing.T) {
 		t.Error("Expected", input, "to return a list of length 2")
 	}
 
-	if (result)[0].(bencodeString) != "spam" {
+	if (result)[0].(String) != "spam" {
 		t.Error("Expected", input, "to return a list with \"spam\" as the first element")
 	}
 
-	if (result)[1].(bencodeInt) != 123 {
+	if (result)[1].(Int) != 123 {
 		t.Error("Expected", input, "to return a list with 123 as the second element")
 	}
 
@@ -103,11 +107,11 @@ func TestDecodeDict(t *testing.T) {
 		t.Error("Expected", input, "to return a dict of length 2")
 	}
 
-	if (result)["cow"].(bencodeString) != "moo" {
+	if (result)["cow"].(String) != "moo" {
 		t.Error("Expected", input, "to return a dict with \"cow\" as the first key")
 	}
 
-	if (result)["spam"].(bencodeString) != "eggs" {
+	if (result)["spam"].(String) != "eggs" {
 		t.Error("Expected", input, "to return a dict with \"spam\" as the second key")
 	}
 
@@ -120,52 +124,96 @@ func TestDecodeDict(t *testing.T) {
 
 func TestDecodeValue(t *testing.T) {
 	var input = "i123e"
-	var result, err = decodeScannerBencodeValue(&scanner{input: input})
-	if result.(bencodeInt) != 123 || err != nil {
+	var result, err = decodeScannerValue(&scanner{input: input})
+	if result.(Int) != 123 || err != nil {
 		t.Error("expected 123, got", result, "err:", err)
 	}
 
 	input = "4:spam"
-	result, err = decodeScannerBencodeValue(&scanner{input: input})
-	if result.(bencodeString) != "spam" || err != nil {
+	result, err = decodeScannerValue(&scanner{input: input})
+	if result.(String) != "spam" || err != nil {
 		t.Error("expected \"spam\", got", result, "err:", err)
 	}
 
 	input = "l4:spami123ee"
-	result, err = decodeScannerBencodeValue(&scanner{input: input})
+	result, err = decodeScannerValue(&scanner{input: input})
 	if err != nil {
 		t.Error("Expected", input, "to return a list")
 	}
 
 	input = "d3:cow3:moo4:spam4:eggse"
-	result, err = decodeScannerBencodeValue(&scanner{input: input})
+	result, err = decodeScannerValue(&scanner{input: input})
 	if err != nil {
 		t.Error("Expected", input, "to return a dict")
 	}
 }
 
 func TestEncodeValue(t *testing.T) {
-	var input bencodeValue = bencodeInt(123)
-	var result = input.encode()
+	var input Value = Int(123)
+	var result = input.Encode()
 	if result != "i123e" {
 		t.Error("expected \"i123e\", got", result)
 	}
 
-	input = bencodeString("spam")
-	result = input.encode()
+	input = String("spam")
+	result = input.Encode()
 	if result != "4:spam" {
 		t.Error("expected \"4:spam\", got", result)
 	}
 
-	input = bencodeList{bencodeString("spam"), bencodeInt(123)}
-	result = input.encode()
+	input = List{String("spam"), Int(123)}
+	result = input.Encode()
 	if result != "l4:spami123ee" {
 		t.Error("expected \"l4:spami123ee\", got", result)
 	}
 
-	input = bencodeDict{"dog": bencodeString("woof"), "cow": bencodeString("moo")}
-	result = input.encode()
+	input = Dict{"dog": String("woof"), "cow": String("moo")}
+	result = input.Encode()
 	if result != "d3:cow3:moo3:dog4:woofe" {
 		t.Error("expected \"d3:cow3:moo3:dog4:woofe\", got", result)
 	}
 }
+
+func TestDecodeLargeIntegers(t *testing.T) {
+	var result, err = Decode("i9223372036854775807e")
+	if result != Int(9223372036854775807) || err != nil {
+		t.Error("expected max int64, got", result, "err:", err)
+	}
+
+	result, err = Decode("i-9223372036854775808e")
+	if result != Int(-9223372036854775808) || err != nil {
+		t.Error("expected min int64, got", result, "err:", err)
+	}
+
+	_, err = Decode("i9223372036854775808e")
+	if err == nil || !strings.Contains(err.Error(), "overflows int64") {
+		t.Error("Expected overflow error, got", err)
+	}
+
+	_, err = Decode("99999999999999999999:spam")
+	if err == nil || !strings.Contains(err.Error(), "overflows int64") {
+		t.Error("Expected overflow error for string length, got", err)
+	}
+
+	var options = DecodeOptions{BigIntegers: true}
+	result, err = options.Decode("i-123456789012345678901234567890e")
+	if big, ok := result.(BigInt); !ok || err != nil || big.String() != "-123456789012345678901234567890" {
+		t.Error("expected big integer, got", result, "err:", err)
+	} else if big.Encode() != "i-123456789012345678901234567890e" {
+		t.Error("expected big integer to encode unchanged, got", big.Encode())
+	}
+
+	var target struct {
+		Size  big.Int `bencode:"size"`
+		Small int64   `bencode:"small,omitempty"`
+	}
+	err = options.Unmarshal([]byte("d4:sizei123456789012345678901234567890ee"), &target)
+	if err != nil || target.Size.String() != "123456789012345678901234567890" {
+		t.Error("expected big.Int field to be set, got", target.Size.String(), "err:", err)
+	}
+
+	err = options.Unmarshal([]byte("d4:sizei1e5:smalli123456789012345678901234567890ee"), &target)
+	if err == nil || !strings.Contains(err.Error(), "small") {
+		t.Error("Expected overflow error naming field \"small\", got", err)
+	}
+}
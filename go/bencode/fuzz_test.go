@@ -0,0 +1,79 @@
+package bencode
+
+import (
+	"testing"
+)
+
+// The seed corpus in testdata/fuzz contains typical DHT packets; these add a few edge cases on top.
+var fuzzSeeds = []string{"i-0e", "i+5e", "i007e", "0:", "le", "de", "d1:ai1e1:ai2ee", "lllleeee", "i9223372036854775808e",
+	"d+1:ai1ee", "d-0:i1ee"}
+
+// checkRoundTrip checks that the encoding of a decoded value is canonical and decodes to the same value.
+func checkRoundTrip(t *testing.T, value Value) {
+	var encoded = value.Encode()
+	var decoded, err = DecodeOptions{Strict: true}.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Expected %q to decode strictly, got %s", encoded, err)
+	}
+
+	if decoded.Encode() != encoded {
+		t.Fatalf("Expected %q to round-trip, got %q", encoded, decoded.Encode())
+	}
+
+	if string(Append(nil, value)) != encoded {
+		t.Fatalf("Expected Append to match Encode %q, got %q", encoded, Append(nil, value))
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var value, err = Decode(string(data))
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, value)
+	})
+}
+
+func FuzzDecodeDict(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var dict, err = DecodeDict(string(data))
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, dict)
+	})
+}
+
+func FuzzParseRaw(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var raw, rest, err = ParseRaw(data)
+		var value, decodeErr = Decode(string(data))
+		if (err == nil) != (decodeErr == nil) {
+			t.Fatalf("Expected ParseRaw and Decode to agree on %q, got %v and %v", data, err, decodeErr)
+		} else if err != nil {
+			return
+		}
+
+		if len(raw)+len(rest) != len(data) {
+			t.Fatalf("Expected raw value and rest to make up the input, got %q and %q", raw, rest)
+		}
+
+		rawValue, err := raw.Value()
+		if err != nil || rawValue.Encode() != value.Encode() {
+			t.Fatalf("Expected raw value %q to convert to %q, got %v, err: %v", raw, value.Encode(), rawValue, err)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package bencode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JSON has no byte strings, so strings that are not printable UTF-8 text - like node IDs or compact node info - are
+// converted to an object with a single marker key and the encoded bytes, e.g. {"$hex": "0a1b"}. Dict keys cannot be
+// objects, so binary keys are converted to the marker and the encoded bytes separated by a colon, e.g. "$hex:0a1b".
+// Strings starting with "$" are treated as binary as well, so they cannot be mistaken for a marker. Integers become
+// JSON numbers, also those beyond the range of int64.
+
+// BinaryEncoding selects how ToJSON converts binary strings.
+type BinaryEncoding int
+
+const (
+	BinaryHex BinaryEncoding = iota
+	BinaryBase64
+)
+
+const hexMarker = "$hex"
+const base64Marker = "$base64"
+
+func (e BinaryEncoding) marker() string {
+	if e == BinaryBase64 {
+		return base64Marker
+	}
+	return hexMarker
+}
+
+func (e BinaryEncoding) encode(s string) string {
+	if e == BinaryBase64 {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	return hex.EncodeToString([]byte(s))
+}
+
+func isText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+
+	for _, r := range s {
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// ToJSON converts v to JSON, with binary strings encoded as selected by binary.
+func ToJSON(v Value, binary BinaryEncoding) ([]byte, error) {
+	return json.Marshal(toJSONValue(v, binary))
+}
+
+func toJSONValue(v Value, binary BinaryEncoding) any {
+	switch v := v.(type) {
+	case Int:
+		return int64(v)
+	case BigInt:
+		return json.Number(v.String())
+	case String:
+		if isText(string(v)) && !strings.HasPrefix(string(v), "$") {
+			return string(v)
+		}
+		return map[string]string{binary.marker(): binary.encode(string(v))}
+	case List:
+		var result = make([]any, len(v))
+		for i, element := range v {
+			result[i] = toJSONValue(element, binary)
+		}
+		return result
+	case Dict:
+		var result = make(map[string]any, len(v))
+		for key, value := range v {
+			if !isText(key) || strings.HasPrefix(key, "$") {
+				key = binary.marker() + ":" + binary.encode(key)
+			}
+			result[key] = toJSONValue(value, binary)
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// FromJSON converts JSON as produced by ToJSON back to a bencode value, accepting both binary encodings. true and
+// false become 1 and 0; null and fractional numbers are rejected.
+func FromJSON(data []byte) (Value, error) {
+	var decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var parsed any
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	} else if decoder.More() {
+		return nil, fmt.Errorf("parsing JSON: trailing data at offset %d", decoder.InputOffset())
+	}
+
+	return fromJSONValue(parsed)
+}
+
+// decodeMarked decodes a binary string given its marker, reporting ok = false for an unknown marker.
+func decodeMarked(marker string, encoded string) (decoded string, ok bool, err error) {
+	var b []byte
+	switch marker {
+	case hexMarker:
+		b, err = hex.DecodeString(encoded)
+	case base64Marker:
+		b, err = base64.StdEncoding.DecodeString(encoded)
+	default:
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", true, fmt.Errorf("decoding %s string %q: %w", marker, encoded, err)
+	}
+	return string(b), true, nil
+}
+
+func fromJSONValue(v any) (Value, error) {
+	switch v := v.(type) {
+	case json.Number:
+		var i, err = strconv.ParseInt(v.String(), 10, 64)
+		if err == nil {
+			return Int(i), nil
+		} else if errors.Is(err, strconv.ErrRange) {
+			if b, ok := new(big.Int).SetString(v.String(), 10); ok {
+				return BigInt{b}, nil
+			}
+		}
+		return nil, fmt.Errorf("number %s is not an integer", v)
+	case bool:
+		if v {
+			return Int(1), nil
+		}
+		return Int(0), nil
+	case string:
+		return String(v), nil
+	case []any:
+		var result = make(List, len(v))
+		for i, element := range v {
+			var value, err = fromJSONValue(element)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = value
+		}
+		return result, nil
+	case map[string]any:
+		if len(v) == 1 {
+			for marker, encoded := range v {
+				if s, isString := encoded.(string); isString {
+					if decoded, ok, err := decodeMarked(marker, s); ok {
+						return String(decoded), err
+					}
+				}
+			}
+		}
+
+		var result = make(Dict, len(v))
+		for key, element := range v {
+			if marker, encoded, found := strings.Cut(key, ":"); found {
+				if decoded, ok, err := decodeMarked(marker, encoded); err != nil {
+					return nil, err
+				} else if ok {
+					key = decoded
+				}
+			}
+
+			var value, err = fromJSONValue(element)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported JSON value %v", v)
+	}
+}
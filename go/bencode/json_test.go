@@ -0,0 +1,55 @@
+package bencode
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	var value = Dict{
+		"t":    String("aa"),
+		"y":    String("r"),
+		"r":    Dict{"id": String("\x00\x01\xfe\xff"), "n": List{Int(-1), String("$hex")}},
+		"\xff": Int(1),
+	}
+
+	var cases = map[BinaryEncoding]string{
+		BinaryHex:    `{"$hex:ff":1,"r":{"id":{"$hex":"0001feff"},"n":[-1,{"$hex":"24686578"}]},"t":"aa","y":"r"}`,
+		BinaryBase64: `{"$base64:/w==":1,"r":{"id":{"$base64":"AAH+/w=="},"n":[-1,{"$base64":"JGhleA=="}]},"t":"aa","y":"r"}`,
+	}
+
+	for encoding, expected := range cases {
+		var result, err = ToJSON(value, encoding)
+		if err != nil || string(result) != expected {
+			t.Error("Expected", expected, "got", string(result), "err:", err)
+		}
+
+		back, err := FromJSON(result)
+		if err != nil || back.Encode() != value.Encode() {
+			t.Error("Expected", string(result), "to convert back, got", back, "err:", err)
+		}
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	var big, _ = new(big.Int).SetString("123456789012345678901234567890", 10)
+	var cases = map[string]Value{
+		`{"a": [1, true, false], "b": "text"}`: Dict{"a": List{Int(1), Int(1), Int(0)}, "b": String("text")},
+		`{"id": {"$hex": "0a0b"}}`:             Dict{"id": String("\x0a\x0b")},
+		`{"$hex": "0a", "other": 1}`:           Dict{"$hex": String("0a"), "other": Int(1)},
+		`123456789012345678901234567890`:       BigInt{big},
+	}
+
+	for input, expected := range cases {
+		var value, err = FromJSON([]byte(input))
+		if err != nil || value.Encode() != expected.Encode() {
+			t.Error("Expected", input, "to convert to", expected, "got", value, "err:", err)
+		}
+	}
+
+	for _, input := range []string{`null`, `1.5`, `{"$hex": "zz"}`, `[1] [2]`, `{"a": `} {
+		if _, err := FromJSON([]byte(input)); err == nil {
+			t.Error("Expected", input, "to fail converting")
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Marshal encodes v, which is mapped to bencode values as follows:
+//
+//   - integer types and bool become Int, bools as 0 or 1
+//   - big.Int and integers beyond the range of int64 become BigInt
+//   - string, []byte and byte arrays become String
+//   - other slices and arrays become List
+//   - maps with string keys become Dict
+//   - structs become Dict, see below
+//   - pointers and interfaces are encoded as the value they point to
+//   - Int, String, List, Dict and Value are encoded as-is
+//
+// Exported struct fields are encoded under the name given in their `bencode:"name"` tag, or under the field name if
+// there is no tag. A tag of "-" skips the field. With the "omitempty" option (`bencode:"name,omitempty"`), zero
+// values are left out when marshalling, and the field is optional when unmarshalling - all other fields are required.
+// The fields of untagged embedded structs are treated as fields of the outer struct.
+func Marshal(v any) ([]byte, error) {
+	var value, err = MarshalValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(value.Encode()), nil
+}
+
+// MarshalValue is like Marshal, but returns the bencode value instead of its encoding.
+func MarshalValue(v any) (Value, error) {
+	return marshalReflect(reflect.ValueOf(v), "")
+}
+
+// Unmarshal decodes data and stores the result in the value pointed to by v, using the mapping described at Marshal.
+// Values that do not fit into v are reported as *FieldError.
+func Unmarshal(data []byte, v any) error {
+	return DecodeOptions{}.Unmarshal(data, v)
+}
+
+// UnmarshalValue is like Unmarshal, but takes an already decoded value.
+func UnmarshalValue(value Value, v any) error {
+	var target = reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("bencode: Unmarshal needs a non-nil pointer, got %T", v)
+	}
+
+	return unmarshalReflect(value, target.Elem(), "")
+}
+
+// FieldError is returned for values that cannot be marshalled or unmarshalled. Field is the path of the offending
+// value, e.g. "nodes[2].id", and empty for the top-level value.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// ErrMissingField is wrapped by the FieldError for a required struct field that is missing from the input.
+var ErrMissingField = errors.New("missing")
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return "bencode: " + e.Err.Error()
+	}
+	return fmt.Sprintf("bencode: field %q: %s", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+type fieldTag struct {
+	name      string
+	omitEmpty bool
+}
+
+func parseFieldTag(field reflect.StructField) (tag fieldTag, skip bool) {
+	var value, hasTag = field.Tag.Lookup("bencode")
+	if value == "-" {
+		return fieldTag{}, true
+	}
+
+	var name, options, _ = strings.Cut(value, ",")
+	if !hasTag || name == "" {
+		name = field.Name
+	}
+
+	return fieldTag{name: name, omitEmpty: options == "omitempty"}, false
+}
+
+func joinField(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+var valueType = reflect.TypeFor[Value]()
+var bigIntType = reflect.TypeFor[big.Int]()
+
+func isByteSequence(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+func marshalReflect(v reflect.Value, path string) (Value, error) {
+	if !v.IsValid() {
+		return nil, &FieldError{Field: path, Err: errors.New("cannot marshal nil")}
+	}
+
+	if v.Type().Implements(valueType) && v.Kind() != reflect.Interface {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, &FieldError{Field: path, Err: errors.New("cannot marshal nil")}
+		}
+		return v.Interface().(Value), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, &FieldError{Field: path, Err: errors.New("cannot marshal nil")}
+		}
+		return marshalReflect(v.Elem(), path)
+	case reflect.Bool:
+		if v.Bool() {
+			return Int(1), nil
+		}
+		return Int(0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return BigInt{new(big.Int).SetUint64(v.Uint())}, nil
+		}
+		return Int(v.Uint()), nil
+	case reflect.String:
+		return String(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if isByteSequence(v.Type()) {
+			var buffer = make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(buffer), v)
+			return String(buffer), nil
+		}
+
+		var result = make(List, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			var element, err = marshalReflect(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, element)
+		}
+		return result, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, &FieldError{Field: path, Err: fmt.Errorf("map key type %s is not a string", v.Type().Key())}
+		}
+
+		var result = make(Dict, v.Len())
+		var iter = v.MapRange()
+		for iter.Next() {
+			var key = iter.Key().String()
+			var element, err = marshalReflect(iter.Value(), joinField(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = element
+		}
+		return result, nil
+	case reflect.Struct:
+		if v.Type() == bigIntType {
+			var value = v.Interface().(big.Int)
+			return BigInt{new(big.Int).Set(&value)}, nil
+		}
+
+		var result = make(Dict)
+		if err := marshalStructFields(v, path, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	default:
+		return nil, &FieldError{Field: path, Err: fmt.Errorf("cannot marshal type %s", v.Type())}
+	}
+}
+
+// isEmbeddedStruct reports whether the fields of field should be treated as if they were declared in the outer struct,
+// which is the case for untagged embedded structs.
+func isEmbeddedStruct(field reflect.StructField) bool {
+	var _, hasTag = field.Tag.Lookup("bencode")
+	return field.Anonymous && !hasTag && field.Type.Kind() == reflect.Struct
+}
+
+func marshalStructFields(v reflect.Value, path string, result Dict) error {
+	var t = v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		var field = t.Field(i)
+		if isEmbeddedStruct(field) {
+			if err := marshalStructFields(v.Field(i), path, result); err != nil {
+				return err
+			}
+			continue
+		} else if !field.IsExported() {
+			continue
+		}
+
+		var tag, skip = parseFieldTag(field)
+		if skip || (tag.omitEmpty && v.Field(i).IsZero()) {
+			continue
+		}
+
+		var element, err = marshalReflect(v.Field(i), joinField(path, tag.name))
+		if err != nil {
+			return err
+		}
+		result[tag.name] = element
+	}
+
+	return nil
+}
+
+func typeName(value Value) string {
+	switch value.(type) {
+	case Int, BigInt:
+		return "integer"
+	case String:
+		return "string"
+	case List:
+		return "list"
+	case Dict:
+		return "dict"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func mismatch(path string, expected string, value Value) error {
+	return &FieldError{Field: path, Err: fmt.Errorf("expected %s, got %s", expected, typeName(value))}
+}
+
+func unmarshalReflect(value Value, v reflect.Value, path string) error {
+	// Fields of type Value (or any) take whatever was decoded.
+	if v.Kind() == reflect.Interface && valueType.AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalReflect(value, v.Elem(), path)
+	case reflect.Bool:
+		i, ok := value.(Int)
+		if !ok {
+			return mismatch(path, "integer", value)
+		}
+		v.SetBool(i != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := value.(Int)
+		if b, isBig := value.(BigInt); isBig {
+			return &FieldError{Field: path, Err: fmt.Errorf("integer %s overflows %s", b, v.Type())}
+		} else if !ok {
+			return mismatch(path, "integer", value)
+		}
+		if v.OverflowInt(int64(i)) {
+			return &FieldError{Field: path, Err: fmt.Errorf("integer %d overflows %s", i, v.Type())}
+		}
+		v.SetInt(int64(i))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := value.(Int)
+		if b, isBig := value.(BigInt); isBig {
+			if !b.IsUint64() || v.OverflowUint(b.Uint64()) {
+				return &FieldError{Field: path, Err: fmt.Errorf("integer %s overflows %s", b, v.Type())}
+			}
+			v.SetUint(b.Uint64())
+			return nil
+		} else if !ok {
+			return mismatch(path, "integer", value)
+		}
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return &FieldError{Field: path, Err: fmt.Errorf("integer %d overflows %s", i, v.Type())}
+		}
+		v.SetUint(uint64(i))
+	case reflect.String:
+		s, ok := value.(String)
+		if !ok {
+			return mismatch(path, "string", value)
+		}
+		v.SetString(string(s))
+	case reflect.Slice:
+		if isByteSequence(v.Type()) {
+			s, ok := value.(String)
+			if !ok {
+				return mismatch(path, "string", value)
+			}
+			v.SetBytes([]byte(s))
+			return nil
+		}
+
+		l, ok := value.(List)
+		if !ok {
+			return mismatch(path, "list", value)
+		}
+
+		var result = reflect.MakeSlice(v.Type(), len(l), len(l))
+		for i, element := range l {
+			if err := unmarshalReflect(element, result.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(result)
+	case reflect.Array:
+		if isByteSequence(v.Type()) {
+			s, ok := value.(String)
+			if !ok {
+				return mismatch(path, "string", value)
+			}
+			if len(s) != v.Len() {
+				return &FieldError{Field: path, Err: fmt.Errorf("expected string of length %d, got %d", v.Len(), len(s))}
+			}
+			reflect.Copy(v, reflect.ValueOf([]byte(s)))
+			return nil
+		}
+
+		l, ok := value.(List)
+		if !ok {
+			return mismatch(path, "list", value)
+		}
+		if len(l) != v.Len() {
+			return &FieldError{Field: path, Err: fmt.Errorf("expected list of length %d, got %d", v.Len(), len(l))}
+		}
+
+		for i, element := range l {
+			if err := unmarshalReflect(element, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return &FieldError{Field: path, Err: fmt.Errorf("map key type %s is not a string", v.Type().Key())}
+		}
+
+		d, ok := value.(Dict)
+		if !ok {
+			return mismatch(path, "dict", value)
+		}
+
+		var result = reflect.MakeMapWithSize(v.Type(), len(d))
+		for key, element := range d {
+			var elementValue = reflect.New(v.Type().Elem()).Elem()
+			if err := unmarshalReflect(element, elementValue, joinField(path, key)); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elementValue)
+		}
+		v.Set(result)
+	case reflect.Struct:
+		if v.Type() == bigIntType {
+			switch i := value.(type) {
+			case Int:
+				v.Set(reflect.ValueOf(big.NewInt(int64(i))).Elem())
+			case BigInt:
+				v.Set(reflect.ValueOf(new(big.Int).Set(i.Int)).Elem())
+			default:
+				return mismatch(path, "integer", value)
+			}
+			return nil
+		}
+
+		d, ok := value.(Dict)
+		if !ok {
+			return mismatch(path, "dict", value)
+		}
+		return unmarshalStructFields(d, v, path)
+	default:
+		return &FieldError{Field: path, Err: fmt.Errorf("cannot unmarshal into type %s", v.Type())}
+	}
+
+	return nil
+}
+
+func unmarshalStructFields(d Dict, v reflect.Value, path string) error {
+	var t = v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		var field = t.Field(i)
+		if isEmbeddedStruct(field) {
+			if err := unmarshalStructFields(d, v.Field(i), path); err != nil {
+				return err
+			}
+			continue
+		} else if !field.IsExported() {
+			continue
+		}
+
+		var tag, skip = parseFieldTag(field)
+		if skip {
+			continue
+		}
+
+		element, ok := d[tag.name]
+		if !ok {
+			if !tag.omitEmpty {
+				return &FieldError{Field: joinField(path, tag.name), Err: ErrMissingField}
+			}
+			continue
+		}
+
+		if err := unmarshalReflect(element, v.Field(i), joinField(path, tag.name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
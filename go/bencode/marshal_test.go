@@ -0,0 +1,123 @@
+package bencode
+
+import (
+	"errors"
+	"testing"
+)
+
+type testNode struct {
+	Id   [4]byte `bencode:"id"`
+	Port uint16  `bencode:"port"`
+}
+
+type testMessage struct {
+	Name     string            `bencode:"name"`
+	Count    int               `bencode:"count,omitempty"`
+	Payload  []byte            `bencode:"payload,omitempty"`
+	Nodes    []testNode        `bencode:"nodes,omitempty"`
+	Extra    map[string]string `bencode:"extra,omitempty"`
+	Raw      Value             `bencode:"raw,omitempty"`
+	Flag     bool              `bencode:"flag,omitempty"`
+	Ignored  string            `bencode:"-"`
+	Untagged int
+	internal int
+}
+
+func TestMarshal(t *testing.T) {
+	var message = testMessage{
+		Name:     "spam",
+		Nodes:    []testNode{{Id: [4]byte{'a', 'b', 'c', 'd'}, Port: 80}},
+		Extra:    map[string]string{"cow": "moo"},
+		Raw:      List{Int(1)},
+		Flag:     true,
+		Ignored:  "ignored",
+		Untagged: 7,
+		internal: 1,
+	}
+
+	var result, err = Marshal(message)
+	var expected = "d8:Untaggedi7e5:extrad3:cow3:mooe4:flagi1e4:name4:spam5:nodesld2:id4:abcd4:porti80eee3:rawli1eee"
+	if err != nil || string(result) != expected {
+		t.Error("expected", expected, "got", string(result), "err:", err)
+	}
+
+	_, err = Marshal(struct {
+		Value *int `bencode:"value"`
+	}{})
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "value" {
+		t.Error("Expected nil pointer to be reported for field \"value\", got", err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var input = "d8:Untaggedi7e5:extrad3:cow3:mooe4:flagi1e4:name4:spam5:nodesld2:id4:abcd4:porti80eee7:payload3:xyz3:rawli1eee"
+	var message testMessage
+	var err = Unmarshal([]byte(input), &message)
+	if err != nil {
+		t.Fatal("Expected", input, "to unmarshal, got", err)
+	}
+
+	if message.Name != "spam" || message.Untagged != 7 || !message.Flag || string(message.Payload) != "xyz" {
+		t.Error("Got wrong scalar fields:", message)
+	}
+	if len(message.Nodes) != 1 || message.Nodes[0].Id != [4]byte{'a', 'b', 'c', 'd'} || message.Nodes[0].Port != 80 {
+		t.Error("Got wrong nodes:", message.Nodes)
+	}
+	if message.Extra["cow"] != "moo" {
+		t.Error("Got wrong extra:", message.Extra)
+	}
+	if raw, ok := message.Raw.(List); !ok || len(raw) != 1 || raw[0].(Int) != 1 {
+		t.Error("Got wrong raw value:", message.Raw)
+	}
+}
+
+func TestUnmarshalFieldErrors(t *testing.T) {
+	var cases = map[string]string{
+		"d8:Untaggedi7ee":          "name",
+		"d8:Untaggedi7e4:namei1ee": "name",
+		"d8:Untaggedi7e4:name4:spam5:nodesld2:id3:abc4:porti80eeee":  "nodes[0].id",
+		"d8:Untaggedi7e4:name4:spam5:nodesld2:id4:abcd4:porti-1eeee": "nodes[0].port",
+		"d8:Untaggedi7e4:name4:spam5:extrad3:cowi1eee":               "extra.cow",
+	}
+
+	for input, field := range cases {
+		var message testMessage
+		var err = Unmarshal([]byte(input), &message)
+
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != field {
+			t.Error("Expected", input, "to fail for field", field, "got", err)
+		}
+	}
+
+	var message testMessage
+	if err := Unmarshal([]byte("l4:spame"), &message); err == nil {
+		t.Error("Expected unmarshalling a list into a struct to fail")
+	}
+	if err := Unmarshal([]byte("de"), message); err == nil {
+		t.Error("Expected unmarshalling into a non-pointer to fail")
+	}
+}
+
+type testEmbedded struct {
+	Inner string `bencode:"inner"`
+}
+
+func TestMarshalEmbeddedStruct(t *testing.T) {
+	var input = struct {
+		testEmbedded
+		Outer string `bencode:"outer"`
+	}{testEmbedded{Inner: "a"}, "b"}
+
+	var result, err = Marshal(input)
+	if err != nil || string(result) != "d5:inner1:a5:outer1:be" {
+		t.Error("expected d5:inner1:a5:outer1:be, got", string(result), "err:", err)
+	}
+
+	input.Inner = ""
+	err = Unmarshal(result, &input)
+	if err != nil || input.Inner != "a" {
+		t.Error("Expected embedded field to be unmarshalled, got", input, "err:", err)
+	}
+}
@@ -0,0 +1,112 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DecodeOptions control how input is decoded. The zero value decodes leniently, which is what the package level
+// functions like Decode and Unmarshal use - e.g. to tolerate slightly malformed packets from other DHT nodes.
+type DecodeOptions struct {
+	// Strict only accepts input in canonical form, which is needed wherever encoded bytes are hashed or signed (e.g.
+	// infohashes and BEP 44 signatures). It rejects integers with a "+" sign, leading zeros or a negative zero, signed
+	// string lengths, dict keys that are not sorted or duplicated, and - for Decode, DecodeDict and Unmarshal - any
+	// data after the value.
+	Strict bool
+	// BigIntegers decodes integers beyond the range of int64 as BigInt instead of failing with an overflow error.
+	BigIntegers bool
+
+	// Limits for decoding untrusted input, zero means unlimited. Exceeding one fails with an error wrapping
+	// ErrLimitExceeded.
+
+	// MaxDepth limits how deeply lists and dicts can be nested; a value that is not a list or dict has depth 0.
+	MaxDepth int
+	// MaxElements limits the number of values in a single decoded value, counting dict keys and the value itself.
+	MaxElements int
+	// MaxStringLength limits the length of a single string.
+	MaxStringLength int
+}
+
+var ErrLimitExceeded = errors.New("decoder limit exceeded")
+
+func (c *scanner) enterContainer() error {
+	c.depth++
+	if c.options.MaxDepth > 0 && c.depth > c.options.MaxDepth {
+		return fmt.Errorf("%w: nesting at offset %d is deeper than %d", ErrLimitExceeded, c.position, c.options.MaxDepth)
+	}
+	return nil
+}
+
+func (c *scanner) leaveContainer() {
+	c.depth--
+}
+
+func (c *scanner) countElement() error {
+	c.elements++
+	if c.options.MaxElements > 0 && c.elements > c.options.MaxElements {
+		return fmt.Errorf("%w: more than %d elements at offset %d", ErrLimitExceeded, c.options.MaxElements, c.position)
+	}
+	return nil
+}
+
+func (c *scanner) checkStringLength(length int64, offset int) error {
+	if c.options.MaxStringLength > 0 && length > int64(c.options.MaxStringLength) {
+		return fmt.Errorf("%w: string of length %d at offset %d is longer than %d", ErrLimitExceeded, length, offset, c.options.MaxStringLength)
+	}
+	return nil
+}
+
+func (o DecodeOptions) newScanner(input string) *scanner {
+	return &scanner{input: input, options: o}
+}
+
+func (o DecodeOptions) checkTrailingData(scanner *scanner) error {
+	if o.Strict && scanner.position < len(scanner.input) {
+		return fmt.Errorf("trailing data at offset %d", scanner.position)
+	}
+	return nil
+}
+
+// Decode decodes the bencoded value at the start of input.
+func (o DecodeOptions) Decode(input string) (Value, error) {
+	var scanner = o.newScanner(input)
+	var value, err = decodeScannerValue(scanner)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, o.checkTrailingData(scanner)
+}
+
+// DecodeDict decodes input, which must start with a bencoded dictionary.
+func (o DecodeOptions) DecodeDict(input string) (Dict, error) {
+	var scanner = o.newScanner(input)
+	if err := scanner.countElement(); err != nil {
+		return nil, err
+	}
+
+	var value, err = decodeDict(scanner)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, o.checkTrailingData(scanner)
+}
+
+// Unmarshal decodes data and stores the result in the value pointed to by v, see the package level Unmarshal.
+func (o DecodeOptions) Unmarshal(data []byte, v any) error {
+	var value, err = o.Decode(string(data))
+	if err != nil {
+		return err
+	}
+
+	return UnmarshalValue(value, v)
+}
+
+// NewDecoder returns a Decoder reading from r with these options.
+func (o DecodeOptions) NewDecoder(r io.Reader) *Decoder {
+	var decoder = NewDecoder(r)
+	decoder.scanner.options = o
+	return decoder
+}
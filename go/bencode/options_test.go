@@ -0,0 +1,113 @@
+package bencode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStrictDecodeRejectsNonCanonicalInput(t *testing.T) {
+	var cases = map[string]string{
+		"i+5e":                    "offset 1",
+		"i-0e":                    "offset 1",
+		"i007e":                   "offset 1",
+		"i-07e":                   "offset 1",
+		"-1:a":                    "offset 0",
+		"04:spam":                 "offset 0",
+		"d3:dog4:woof3:cow3:mooe": "offset 12",
+		"d3:cow3:moo3:cow3:mooe":  "offset 11",
+		"i1ei2e":                  "offset 3",
+		"l4:spamei1e":             "offset 8",
+	}
+
+	var strict = DecodeOptions{Strict: true}
+	for input, offset := range cases {
+		var _, err = strict.Decode(input)
+		if err == nil || !strings.Contains(err.Error(), offset) {
+			t.Error("Expected", input, "to fail strict decoding at", offset, "got", err)
+		}
+
+		if _, err := Decode(input); err != nil && !strings.HasPrefix(input, "-") {
+			t.Error("Expected", input, "to decode leniently, got", err)
+		}
+	}
+}
+
+func TestStrictDecodeAcceptsCanonicalInput(t *testing.T) {
+	var strict = DecodeOptions{Strict: true}
+	for _, input := range []string{"i0e", "i-5e", "i10e", "0:", "d3:cow3:moo4:spam4:eggse", "ld1:ai1eeli-1eee"} {
+		var value, err = strict.Decode(input)
+		if err != nil || value.Encode() != input {
+			t.Error("Expected", input, "to decode strictly, got", value, "err:", err)
+		}
+	}
+
+	if _, err := strict.DecodeDict("d1:bi1e1:ai2ee"); err == nil {
+		t.Error("Expected DecodeDict to reject unsorted keys")
+	}
+
+	var decoder = strict.NewDecoder(strings.NewReader("i1ei+2e"))
+	if _, err := decoder.DecodeValue(); err != nil {
+		t.Error("Expected first value to decode, got", err)
+	}
+	if _, err := decoder.DecodeValue(); err == nil {
+		t.Error("Expected stream decoder to reject \"i+2e\" in strict mode")
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	var cases = []struct {
+		options DecodeOptions
+		input   string
+	}{
+		{DecodeOptions{MaxDepth: 2}, "llleee"},
+		{DecodeOptions{MaxDepth: 1}, "d1:ali1eee"},
+		{DecodeOptions{MaxElements: 3}, "li1ei2ei3ee"},
+		{DecodeOptions{MaxElements: 4}, "d1:ai1e1:bi2ee"},
+		{DecodeOptions{MaxStringLength: 3}, "l4:spame"},
+	}
+
+	for _, c := range cases {
+		if _, err := c.options.Decode(c.input); !errors.Is(err, ErrLimitExceeded) {
+			t.Error("Expected", c.input, "to exceed", c.options, "got", err)
+		}
+
+		if _, _, err := c.options.ParseRaw([]byte(c.input)); !errors.Is(err, ErrLimitExceeded) {
+			t.Error("Expected ParseRaw of", c.input, "to exceed", c.options, "got", err)
+		}
+
+		if _, err := c.options.NewDecoder(strings.NewReader(c.input)).DecodeValue(); !errors.Is(err, ErrLimitExceeded) {
+			t.Error("Expected Decoder of", c.input, "to exceed", c.options, "got", err)
+		}
+
+		if _, err := Decode(c.input); err != nil {
+			t.Error("Expected", c.input, "to decode without limits, got", err)
+		}
+	}
+
+	var options = DecodeOptions{MaxDepth: 2, MaxElements: 4, MaxStringLength: 4}
+	if _, err := options.Decode("ld3:cow3:mooee"); err != nil {
+		t.Error("Expected value within limits to decode, got", err)
+	}
+
+	// A huge announced length must fail before anything is read or allocated.
+	if _, err := options.Decode("999999999999:"); !errors.Is(err, ErrLimitExceeded) {
+		t.Error("Expected huge string length to exceed the limit, got", err)
+	}
+
+	// The element count applies to each value of a stream separately.
+	var decoder = DecodeOptions{MaxElements: 3}.NewDecoder(strings.NewReader("li1ei2eeli3ei4ee"))
+	for range 2 {
+		if _, err := decoder.DecodeValue(); err != nil {
+			t.Error("Expected stream value to decode, got", err)
+		}
+	}
+
+	decoder = DecodeOptions{MaxDepth: 1}.NewDecoder(strings.NewReader("lle"))
+	if _, err := decoder.Token(); err != nil {
+		t.Error("Expected first token to be read, got", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, ErrLimitExceeded) {
+		t.Error("Expected nested token to exceed the depth limit, got", err)
+	}
+}
@@ -0,0 +1,308 @@
+package bencode
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"strconv"
+)
+
+// This file contains an allocation-free alternative to Decode and Encode for hot paths like handling KRPC packets:
+// ParseRaw checks the structure of a value without building Int, String, List or Dict values from it, and the Raw
+// it returns gives access to the parts of the value as slices of the original buffer. The Append functions encode by
+// appending to a caller-provided buffer, which can be reused between packets.
+
+// Raw is the encoding of a single value, usually a slice of a larger buffer.
+type Raw []byte
+
+type Kind int
+
+const (
+	KindInvalid Kind = iota
+	KindInt
+	KindString
+	KindList
+	KindDict
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInt:
+		return "integer"
+	case KindString:
+		return "string"
+	case KindList:
+		return "list"
+	case KindDict:
+		return "dict"
+	default:
+		return "invalid"
+	}
+}
+
+// ParseRaw checks that buf starts with a well-formed value and returns it, along with the bytes that follow it.
+// Neither is copied. ParseRaw is lenient and does not enforce any limits, see DecodeOptions.ParseRaw.
+func ParseRaw(buf []byte) (value Raw, rest []byte, err error) {
+	return DecodeOptions{}.ParseRaw(buf)
+}
+
+// ParseRaw is like the package level ParseRaw, but enforces the limits of o. Strict and BigIntegers are not
+// supported and ignored.
+func (o DecodeOptions) ParseRaw(buf []byte) (value Raw, rest []byte, err error) {
+	var end, parseErr = skipRaw(buf, 0, &rawLimits{options: o})
+	if parseErr != nil {
+		return nil, nil, parseErr
+	}
+
+	return Raw(buf[:end]), buf[end:], nil
+}
+
+// rawLimits tracks the limits of DecodeOptions while skipping over a value. Skipping with nil limits is unlimited,
+// which is used when iterating over a Raw that was already checked by ParseRaw.
+type rawLimits struct {
+	options  DecodeOptions
+	depth    int
+	elements int
+}
+
+func (l *rawLimits) countElement(position int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.elements++
+	if l.options.MaxElements > 0 && l.elements > l.options.MaxElements {
+		return fmt.Errorf("%w: more than %d elements at offset %d", ErrLimitExceeded, l.options.MaxElements, position)
+	}
+	return nil
+}
+
+// parseRawInteger parses the digits starting at position up to the terminator byte, the way parseInteger does.
+func parseRawInteger(buf []byte, position int, terminator byte) (value int64, end int, err error) {
+	var start = position
+	var negative = false
+	if position < len(buf) && (buf[position] == '-' || buf[position] == '+') {
+		negative = buf[position] == '-'
+		position++
+	}
+
+	var digitsStart = position
+	for position < len(buf) && buf[position] >= '0' && buf[position] <= '9' {
+		position++
+	}
+
+	if position == digitsStart {
+		return 0, 0, fmt.Errorf("unexpected integer with zero digits at offset %d", start)
+	} else if position >= len(buf) || buf[position] != terminator {
+		return 0, 0, fmt.Errorf("expected %q after integer at offset %d", terminator, position)
+	}
+
+	// Accumulate negatively, since the range of int reaches one further into the negative.
+	for _, digit := range buf[digitsStart:position] {
+		if value < (math.MinInt64+int64(digit-'0'))/10 {
+			return 0, 0, fmt.Errorf("integer at offset %d overflows int64", start)
+		}
+		value = value*10 - int64(digit-'0')
+	}
+
+	if !negative {
+		if value == math.MinInt64 {
+			return 0, 0, fmt.Errorf("integer at offset %d overflows int64", start)
+		}
+		value = -value
+	}
+
+	return value, position + 1, nil
+}
+
+// skipRaw returns the position just after the value starting at position.
+func skipRaw(buf []byte, position int, limits *rawLimits) (int, error) {
+	if position >= len(buf) {
+		return 0, fmt.Errorf("unexpected end of input")
+	}
+
+	if err := limits.countElement(position); err != nil {
+		return 0, err
+	}
+
+	switch buf[position] {
+	case 'i':
+		var _, end, err = parseRawInteger(buf, position+1, 'e')
+		return end, err
+	case 'l', 'd':
+		if limits != nil {
+			limits.depth++
+			defer func() { limits.depth-- }()
+			if limits.options.MaxDepth > 0 && limits.depth > limits.options.MaxDepth {
+				return 0, fmt.Errorf("%w: nesting at offset %d is deeper than %d", ErrLimitExceeded, position, limits.options.MaxDepth)
+			}
+		}
+
+		var isDict = buf[position] == 'd'
+		position++
+		for {
+			if position >= len(buf) {
+				return 0, fmt.Errorf("unexpected end of input")
+			} else if buf[position] == 'e' {
+				return position + 1, nil
+			}
+
+			if isDict {
+				// Keys are strings, whose lengths may be signed like those of string values.
+				if c := buf[position]; (c < '0' || c > '9') && c != '+' && c != '-' {
+					return 0, fmt.Errorf("expected dict key at offset %d", position)
+				}
+
+				var err error
+				if position, err = skipRaw(buf, position, limits); err != nil {
+					return 0, err
+				}
+			}
+
+			var err error
+			if position, err = skipRaw(buf, position, limits); err != nil {
+				return 0, err
+			}
+		}
+	default:
+		var length, start, err = parseRawInteger(buf, position, ':')
+		if err != nil {
+			return 0, err
+		} else if length < 0 {
+			return 0, fmt.Errorf("unexpected negative string length at offset %d", position)
+		} else if limits != nil && limits.options.MaxStringLength > 0 && length > int64(limits.options.MaxStringLength) {
+			return 0, fmt.Errorf("%w: string of length %d at offset %d is longer than %d", ErrLimitExceeded, length, position, limits.options.MaxStringLength)
+		} else if length > int64(len(buf)-start) {
+			return 0, fmt.Errorf("unexpected end of input")
+		}
+
+		return start + int(length), nil
+	}
+}
+
+func (r Raw) Kind() Kind {
+	if len(r) == 0 {
+		return KindInvalid
+	}
+
+	switch r[0] {
+	case 'i':
+		return KindInt
+	case 'l':
+		return KindList
+	case 'd':
+		return KindDict
+	default:
+		return KindString
+	}
+}
+
+func (r Raw) Int() (int64, error) {
+	if r.Kind() != KindInt {
+		return 0, fmt.Errorf("expected integer, got %s", r.Kind())
+	}
+
+	var value, _, err = parseRawInteger(r, 1, 'e')
+	return value, err
+}
+
+// Bytes returns the content of a string value. It references the buffer r was parsed from.
+func (r Raw) Bytes() ([]byte, error) {
+	if r.Kind() != KindString {
+		return nil, fmt.Errorf("expected string, got %s", r.Kind())
+	}
+
+	var _, start, err = parseRawInteger(r, 0, ':')
+	if err != nil {
+		return nil, err
+	}
+
+	return r[start:], nil
+}
+
+// Elements iterates over the elements of a list. It yields nothing if r is not a list.
+func (r Raw) Elements() iter.Seq[Raw] {
+	return func(yield func(Raw) bool) {
+		if r.Kind() != KindList {
+			return
+		}
+
+		for position := 1; position < len(r) && r[position] != 'e'; {
+			var end, err = skipRaw(r, position, nil)
+			if err != nil || !yield(r[position:end]) {
+				return
+			}
+			position = end
+		}
+	}
+}
+
+// Entries iterates over the keys and values of a dict, in the order they appear in the input. It yields nothing if r
+// is not a dict.
+func (r Raw) Entries() iter.Seq2[[]byte, Raw] {
+	return func(yield func([]byte, Raw) bool) {
+		if r.Kind() != KindDict {
+			return
+		}
+
+		for position := 1; position < len(r) && r[position] != 'e'; {
+			var keyEnd, err = skipRaw(r, position, nil)
+			if err != nil {
+				return
+			}
+
+			valueEnd, err := skipRaw(r, keyEnd, nil)
+			if err != nil {
+				return
+			}
+
+			var key, _ = Raw(r[position:keyEnd]).Bytes()
+			if !yield(key, r[keyEnd:valueEnd]) {
+				return
+			}
+			position = valueEnd
+		}
+	}
+}
+
+// Lookup returns the value stored under key in a dict.
+func (r Raw) Lookup(key string) (Raw, bool) {
+	for k, v := range r.Entries() {
+		if string(k) == key {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// Value converts r into an Int, String, List or Dict, copying all data out of the underlying buffer.
+func (r Raw) Value() (Value, error) {
+	return Decode(string(r))
+}
+
+// AppendInt appends the encoding of i to dst.
+func AppendInt(dst []byte, i int64) []byte {
+	dst = append(dst, 'i')
+	dst = strconv.AppendInt(dst, i, 10)
+	return append(dst, 'e')
+}
+
+// AppendString appends the encoding of s to dst.
+func AppendString(dst []byte, s string) []byte {
+	dst = strconv.AppendInt(dst, int64(len(s)), 10)
+	dst = append(dst, ':')
+	return append(dst, s...)
+}
+
+// AppendBytes appends the encoding of b as a string to dst.
+func AppendBytes(dst []byte, b []byte) []byte {
+	dst = strconv.AppendInt(dst, int64(len(b)), 10)
+	dst = append(dst, ':')
+	return append(dst, b...)
+}
+
+// Append appends the encoding of v to dst.
+func Append(dst []byte, v Value) []byte {
+	return v.appendTo(dst)
+}
@@ -0,0 +1,143 @@
+package bencode
+
+import (
+	"testing"
+)
+
+// A get_peers query and a find_node response, as typically seen on the network.
+var getPeersQuery = []byte("d1:ad2:id20:abcdefghij01234567899:info_hash20:mnopqrstuvwxyz123456e1:q9:get_peers1:t2:aa1:y1:qe")
+var findNodeResponse = []byte("d1:rd2:id20:0123456789abcdefghij5:nodes208:" +
+	"01234567890123456789012345012345678901234567890123450123456789012345678901234501234567890123456789012345" +
+	"01234567890123456789012345012345678901234567890123450123456789012345678901234501234567890123456789012345" +
+	"e1:t2:aa1:y1:re")
+
+func TestParseRaw(t *testing.T) {
+	var raw, rest, err = ParseRaw(append(getPeersQuery, "i1e"...))
+	if err != nil || string(raw) != string(getPeersQuery) || string(rest) != "i1e" {
+		t.Fatal("Expected query to parse with \"i1e\" left over, got", string(raw), string(rest), "err:", err)
+	}
+
+	if raw.Kind() != KindDict {
+		t.Error("Expected dict, got", raw.Kind())
+	}
+
+	var args, ok = raw.Lookup("a")
+	if !ok {
+		t.Fatal("Expected \"a\" to be present")
+	}
+
+	infoHash, _ := args.Lookup("info_hash")
+	if b, err := infoHash.Bytes(); err != nil || string(b) != "mnopqrstuvwxyz123456" {
+		t.Error("Expected info_hash, got", string(b), "err:", err)
+	}
+
+	if _, ok := raw.Lookup("missing"); ok {
+		t.Error("Expected missing key to not be found")
+	}
+
+	list, _, _ := ParseRaw([]byte("li-12e4:spamli1eee"))
+	var elements []string
+	for element := range list.Elements() {
+		elements = append(elements, string(element))
+	}
+	if len(elements) != 3 || elements[0] != "i-12e" || elements[1] != "4:spam" || elements[2] != "li1ee" {
+		t.Error("Got wrong list elements:", elements)
+	}
+
+	if i, err := Raw(elements[0]).Int(); err != nil || i != -12 {
+		t.Error("Expected -12, got", i, "err:", err)
+	}
+
+	value, err := raw.Value()
+	if err != nil || value.Encode() != string(getPeersQuery) {
+		t.Error("Expected conversion to Value to round-trip, got", value, "err:", err)
+	}
+
+	// Signed key lengths are as lenient as signed string lengths, like in Decode.
+	for _, input := range []string{"d+1:ai1ee", "d-0:i1ee"} {
+		if dict, _, err := ParseRaw([]byte(input)); err != nil {
+			t.Error("Expected", input, "to parse, got", err)
+		} else if _, ok := dict.Lookup(""); input == "d-0:i1ee" && !ok {
+			t.Error("Expected the empty key in", input)
+		}
+	}
+}
+
+func TestParseRawErrors(t *testing.T) {
+	for _, input := range []string{"", "i12", "ie", "l4:spam", "5:spam", "-1:a", "d-1:ai1ee", "d4:spame", "di1ei2ee", "x", "i99999999999999999999e"} {
+		if _, _, err := ParseRaw([]byte(input)); err == nil {
+			t.Error("Expected", input, "to return an error")
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	var buffer = make([]byte, 0, 64)
+	buffer = Append(buffer, Dict{"dog": String("woof"), "cow": List{Int(-1)}})
+	if string(buffer) != "d3:cowli-1ee3:dog4:woofe" {
+		t.Error("expected d3:cowli-1ee3:dog4:woofe, got", string(buffer))
+	}
+
+	buffer = AppendBytes(AppendInt(buffer[:0], 42), []byte("spam"))
+	if string(buffer) != "i42e4:spam" {
+		t.Error("expected i42e4:spam, got", string(buffer))
+	}
+}
+
+func BenchmarkDecodeQuery(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		var dict, _ = DecodeDict(string(getPeersQuery))
+		_ = dict["a"].(Dict)["info_hash"].(String)
+	}
+}
+
+func BenchmarkParseRawQuery(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		var raw, _, _ = ParseRaw(getPeersQuery)
+		var args, _ = raw.Lookup("a")
+		var infoHash, _ = args.Lookup("info_hash")
+		_, _ = infoHash.Bytes()
+	}
+}
+
+func BenchmarkDecodeResponse(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		var dict, _ = DecodeDict(string(findNodeResponse))
+		_ = dict["r"].(Dict)["nodes"].(String)
+	}
+}
+
+func BenchmarkParseRawResponse(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		var raw, _, _ = ParseRaw(findNodeResponse)
+		var returnValues, _ = raw.Lookup("r")
+		var nodes, _ = returnValues.Lookup("nodes")
+		_, _ = nodes.Bytes()
+	}
+}
+
+var queryDict = Dict{
+	"t": String("aa"),
+	"y": String("q"),
+	"q": String("get_peers"),
+	"a": Dict{"id": String("abcdefghij0123456789"), "info_hash": String("mnopqrstuvwxyz123456")},
+}
+
+func BenchmarkEncodeQuery(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		_ = []byte(queryDict.Encode())
+	}
+}
+
+func BenchmarkAppendQuery(b *testing.B) {
+	b.ReportAllocs()
+	var buffer = make([]byte, 0, 1500)
+	for range b.N {
+		buffer = Append(buffer[:0], queryDict)
+	}
+}
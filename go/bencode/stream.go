@@ -0,0 +1,148 @@
+package bencode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decoder reads bencoded values from a stream, either as complete values or token by token.
+type Decoder struct {
+	scanner *scanner
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	var reader, ok = r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+
+	return &Decoder{scanner: &scanner{reader: reader}}
+}
+
+// InputOffset returns the number of bytes consumed from the stream so far.
+func (d *Decoder) InputOffset() int {
+	return d.scanner.position
+}
+
+// wrapError prefers a read error of the underlying reader over the resulting decoding error.
+func (d *Decoder) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if d.scanner.readErr != nil && !errors.Is(d.scanner.readErr, io.EOF) {
+		return fmt.Errorf("reading bencode stream: %w", d.scanner.readErr)
+	}
+
+	return fmt.Errorf("decoding bencode stream at offset %d: %w", d.scanner.position, err)
+}
+
+// atEnd reports whether the stream ended cleanly before the next value.
+func (d *Decoder) atEnd() bool {
+	var _, eof = d.scanner.peek()
+	return eof && errors.Is(d.scanner.readErr, io.EOF)
+}
+
+// DecodeValue reads the next complete value from the stream. It returns io.EOF if the stream ends before a new value
+// starts, so a stream of concatenated values can be read until then.
+func (d *Decoder) DecodeValue() (Value, error) {
+	if d.atEnd() {
+		return nil, io.EOF
+	}
+
+	d.scanner.elements = 0
+	var value, err = decodeScannerValue(d.scanner)
+	return value, d.wrapError(err)
+}
+
+// Decode reads the next complete value from the stream and unmarshals it into v, see Unmarshal.
+func (d *Decoder) Decode(v any) error {
+	var value, err = d.DecodeValue()
+	if err != nil {
+		return err
+	}
+
+	return UnmarshalValue(value, v)
+}
+
+// Delim is the start or end of a list or dict in the token stream.
+type Delim byte
+
+const (
+	ListStart Delim = 'l'
+	DictStart Delim = 'd'
+	End       Delim = 'e'
+)
+
+func (d Delim) String() string {
+	return string(d)
+}
+
+// Token is Int, String or Delim. Dict keys appear as String tokens, alternating with their values.
+type Token any
+
+// Token returns the next token in the stream, without reading lists or dicts as a whole. This allows large values to
+// be processed piece by piece, e.g. to skip over the "pieces" of a torrent. It returns io.EOF at the end of the stream.
+// Token does not check that the tokens form valid values, e.g. that every list is closed. Of the limits in
+// DecodeOptions, MaxDepth and MaxStringLength apply to tokens, while MaxElements applies to each DecodeValue call.
+func (d *Decoder) Token() (Token, error) {
+	if d.atEnd() {
+		return nil, io.EOF
+	}
+
+	var c, _ = d.scanner.peek()
+	switch c {
+	case 'i':
+		var value, err = decodeInteger(d.scanner)
+		return value, d.wrapError(err)
+	case 'l', 'd':
+		if err := d.scanner.enterContainer(); err != nil {
+			return nil, err
+		}
+		d.scanner.next()
+		return Delim(c), nil
+	case 'e':
+		if d.scanner.depth > 0 {
+			d.scanner.leaveContainer()
+		}
+		d.scanner.next()
+		return Delim(c), nil
+	default:
+		var value, err = decodeString(d.scanner)
+		return value, d.wrapError(err)
+	}
+}
+
+// More reports whether there is another element in the current list or dict.
+func (d *Decoder) More() bool {
+	var c, eof = d.scanner.peek()
+	return !eof && c != 'e'
+}
+
+// Encoder writes bencoded values to a stream.
+type Encoder struct {
+	writer io.Writer
+	// buffer is reused for every value, which is written to the stream in one piece.
+	buffer []byte
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{writer: w}
+}
+
+// Encode writes v to the stream. v is either a Value, or anything Marshal accepts.
+func (e *Encoder) Encode(v any) error {
+	var value, ok = v.(Value)
+	if !ok {
+		var err error
+		if value, err = MarshalValue(v); err != nil {
+			return err
+		}
+	}
+
+	e.buffer = Append(e.buffer[:0], value)
+	_, err := e.writer.Write(e.buffer)
+	return err
+}
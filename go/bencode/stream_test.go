@@ -0,0 +1,96 @@
+package bencode
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoderDecodeValue(t *testing.T) {
+	var input = "i123e4:spaml4:spami123eed3:cow3:mooe"
+	var decoder = NewDecoder(iotest.OneByteReader(strings.NewReader(input)))
+
+	var expected = []string{"i123e", "4:spam", "l4:spami123ee", "d3:cow3:mooe"}
+	for _, e := range expected {
+		var value, err = decoder.DecodeValue()
+		if err != nil || value.Encode() != e {
+			t.Error("expected", e, "got", value, "err:", err)
+		}
+	}
+
+	if _, err := decoder.DecodeValue(); err != io.EOF {
+		t.Error("Expected io.EOF at the end of the stream, got", err)
+	}
+
+	if decoder.InputOffset() != len(input) {
+		t.Error("Expected offset", len(input), "got", decoder.InputOffset())
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	var decoder = NewDecoder(strings.NewReader("l4:spam"))
+	if _, err := decoder.DecodeValue(); err == nil || err == io.EOF {
+		t.Error("Expected truncated list to return an error, got", err)
+	}
+
+	decoder = NewDecoder(strings.NewReader("10:short"))
+	if _, err := decoder.DecodeValue(); err == nil || err == io.EOF {
+		t.Error("Expected truncated string to return an error, got", err)
+	}
+
+	var readErr = errors.New("broken")
+	decoder = NewDecoder(iotest.ErrReader(readErr))
+	if _, err := decoder.DecodeValue(); !errors.Is(err, readErr) {
+		t.Error("Expected read error to be returned, got", err)
+	}
+}
+
+func TestDecoderToken(t *testing.T) {
+	var decoder = NewDecoder(strings.NewReader("d4:infod6:lengthi5eee"))
+	var expected = []Token{DictStart, String("info"), DictStart, String("length"), Int(5), End, End}
+
+	for _, e := range expected {
+		var token, err = decoder.Token()
+		if err != nil || token != e {
+			t.Error("expected", e, "got", token, "err:", err)
+		}
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		t.Error("Expected io.EOF at the end of the stream, got", err)
+	}
+}
+
+func TestDecoderMore(t *testing.T) {
+	var decoder = NewDecoder(strings.NewReader("li1ei2ee"))
+	decoder.Token()
+
+	var count = 0
+	for decoder.More() {
+		if _, err := decoder.DecodeValue(); err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+
+	if count != 2 {
+		t.Error("Expected 2 list elements, got", count)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var buffer strings.Builder
+	var encoder = NewEncoder(&buffer)
+
+	encoder.Encode(Dict{"dog": String("woof"), "cow": List{Int(1)}})
+	encoder.Encode(struct {
+		Name string `bencode:"name"`
+	}{"spam"})
+
+	var expected = "d3:cowli1ee3:dog4:woofed4:name4:spame"
+	if buffer.String() != expected {
+		t.Error("expected", expected, "got", buffer.String())
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"dhtcli/bencode"
+	"dhtcli/dht"
+	"dhtcli/metainfo"
+)
+
+func getMyIp() (net.IP, error) {
+	var res, err = http.Get("https://api.ipify.org")
+	if err != nil {
+		return nil, fmt.Errorf("fetching own IP address: %w", err)
+	}
+	defer res.Body.Close()
+
+	ipStr, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading own IP address response body: %w", err)
+	}
+	// TODO: check and handle for unsuccessful HTTP status codes
+	var result = net.ParseIP(string(ipStr))
+	return result, nil
+}
+
+func printUsage() {
+	fmt.Println("available commands:")
+	fmt.Println("  ping <ip:port>")
+	fmt.Println("  find_node <ip:port> <node id>")
+	fmt.Println("  get_peers <ip:port> <infohash or magnet link>")
+	fmt.Println("  announce <ip:port> <infohash or magnet link> <port> (port 0 uses our source port)")
+	fmt.Println("  get <ip:port> <target>")
+	fmt.Println("  put <ip:port> <string> (stores an immutable item)")
+	fmt.Println("  rt (print routing table and statistics per bucket)")
+	fmt.Println("  rt json|dot [file] (export routing table as JSON or Graphviz DOT)")
+	fmt.Println("  ids (print our node ids, with -virtual)")
+	fmt.Println("  stats (print statistics on incoming queries and the routing table)")
+	fmt.Println("  block <cidr or range> / allow <cidr or range> (exceptions to blocked ranges)")
+	fmt.Println("  blocklist <file> (loads a P2P format blocklist, reloaded when it changes)")
+	fmt.Println("  quit")
+}
+
+func main() {
+	var bucketSize = flag.Int("k", dht.DefaultBucketSize, "number of nodes per routing table bucket")
+	var extendedBuckets = flag.Bool("extended-buckets", false, "use larger buckets close to our own id")
+	var relaxedSplit = flag.Bool("relaxed-split", false, "also split buckets in the region of the k closest nodes")
+	var virtualNodes = flag.Int("virtual", 0, "number of additional node ids spread over the id space on the same socket")
+	flag.Parse()
+
+	var args = flag.Args()
+	if len(args) < 1 {
+		fmt.Println("usage:", os.Args[0], "[-k n] [-extended-buckets] [-relaxed-split] [-virtual n] <listen address> [node id]")
+		fmt.Println("      ", os.Args[0], "infohash <file.torrent>")
+		fmt.Println("      ", os.Args[0], "bencode [-base64] [-encode] [file...]")
+		fmt.Println("      ", os.Args[0], "crawl [-rate n] [-workers n] [-max n] [-duration d] [-samples] [-format jsonl|csv] [-o file] <ip:port>...")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "infohash":
+		printInfoHash(args[1:])
+		return
+	case "bencode":
+		convertBencode(args[1:])
+		return
+	case "crawl":
+		crawlNetwork(args[1:])
+		return
+	}
+
+	var filter = dht.NewIPFilter()
+	var config = dht.Config{
+		ListenAddress: args[0],
+		BucketSize:    *bucketSize,
+		RoutingTableLayout: dht.RoutingTableLayout{
+			ExtendedBuckets: *extendedBuckets,
+			RelaxedSplit:    *relaxedSplit,
+		},
+		IPFilter: filter,
+		Logger:   log.New(os.Stdout, "", 0),
+	}
+
+	// Running on a local address is only useful for talking to other local nodes, so accept them as contacts, even
+	// though they share one IP.
+	if listenOn, err := net.ResolveUDPAddr("udp", config.ListenAddress); err == nil && listenOn.IP != nil {
+		config.AddressPolicy.AllowLoopback = listenOn.IP.IsLoopback()
+		config.AddressPolicy.AllowPrivate = listenOn.IP.IsPrivate()
+		config.SybilLimits.Unlimited = listenOn.IP.IsLoopback() || listenOn.IP.IsPrivate()
+	}
+
+	config.OnRoutingEvent = func(event dht.RoutingEvent) {
+		fmt.Println("routing table:", event.Type, "for", event.Node.NodeId, "at", &event.Node.Address)
+	}
+
+	if len(args) >= 2 {
+		var err error
+		config.NodeId, err = dht.ParseNodeId(args[1])
+		if err != nil {
+			log.Fatalf("error parsing node id: %s", err)
+		}
+	}
+
+	node, err := dht.NewNode(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	virtualIds, err := dht.SpreadNodeIds(*virtualNodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, id := range virtualIds {
+		if _, err := node.AddVirtualNode(id); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := node.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer node.Close()
+
+	var listenOn = node.Address()
+	fmt.Println("Listening on", &listenOn, "with node id", node.Id())
+	for _, id := range virtualIds {
+		fmt.Println("Virtual node id", id)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return
+			}
+			fmt.Println("read error:", err)
+			continue
+		}
+
+		parts := strings.Fields(input)
+		if len(parts) == 0 {
+			printUsage()
+			continue
+		}
+
+		command := parts[0]
+		args := parts[1:]
+
+		switch command {
+		case "quit":
+			fmt.Println("Exiting...")
+			return
+
+		case "rt":
+			if len(args) > 0 {
+				if err := exportRoutingTable(node, args); err != nil {
+					fmt.Println(err)
+				}
+				continue
+			}
+
+			node.PrintRoutingTable()
+			var table = node.RoutingTableStats()
+			for i, bucket := range table.Buckets {
+				fmt.Printf("%3d: prefix length %d, %d nodes, %d good, %d questionable, %d bad, average age %s\n", i,
+					bucket.PrefixLength, bucket.Entries, bucket.Good, bucket.Questionable, bucket.Bad,
+					bucket.AverageAge.Round(time.Second))
+			}
+
+		case "ids":
+			for _, identity := range node.VirtualNodes() {
+				fmt.Println(identity.Id(), "with", identity.RoutingTableStats().Entries, "nodes in its routing table")
+			}
+
+		case "stats":
+			var queue = node.QueryQueueStats()
+			fmt.Println("queries handled:", queue.Handled, "dropped:", queue.Dropped)
+			fmt.Println("query queue depth:", queue.QueueDepth, "max:", queue.MaxQueueDepth)
+			fmt.Println("query latency average:", queue.AverageLatency, "max:", queue.MaxLatency)
+
+			var limits = node.RateLimitStats()
+			fmt.Println("queries allowed:", limits.Allowed)
+			fmt.Println("queries over the limit per ip:", limits.LimitedIP, "per prefix:", limits.LimitedPrefix,
+				"global:", limits.LimitedGlobal, "answered with an error:", limits.RepliedLimited)
+			fmt.Println("packets from blocked addresses:", filter.Dropped())
+			fmt.Println("contacts rejected for their address:", node.RejectedContacts())
+
+			var table = node.RoutingTableStats()
+			fmt.Println("routing table nodes:", table.Entries, "good:", table.Good, "questionable:", table.Questionable,
+				"bad:", table.Bad, "average age:", table.AverageAge.Round(time.Second))
+			fmt.Println("estimated network size:", table.EstimatedNetworkSize)
+
+		case "block", "allow":
+			if len(args) != 1 {
+				printUsage()
+				continue
+			}
+
+			var err error
+			if command == "block" {
+				err = filter.Block(args[0])
+			} else {
+				err = filter.Allow(args[0])
+			}
+			if err != nil {
+				fmt.Println(command, "failed:", err)
+			}
+
+		case "blocklist":
+			if len(args) != 1 {
+				printUsage()
+				continue
+			}
+
+			if err := filter.LoadFile(args[0]); err != nil {
+				fmt.Println("loading blocklist failed:", err)
+			}
+
+		case "ping":
+			if len(args) != 1 {
+				printUsage()
+				continue
+			}
+
+			addr, err := net.ResolveUDPAddr("udp", args[0])
+			if err != nil {
+				fmt.Println("invalid address:", err)
+				continue
+			}
+
+			id, err := node.Ping(*addr)
+			if err != nil {
+				fmt.Println("ping failed:", err)
+				continue
+			}
+			fmt.Println("pong from", id)
+
+		case "find_node":
+			addr, target, ok := parseAddressAndId(args)
+			if !ok {
+				continue
+			}
+
+			nodes, err := node.ClosestIdentity(target).FindNode(*addr, target)
+			if err != nil {
+				fmt.Println("find_node failed:", err)
+				continue
+			}
+			printNodes(nodes)
+
+		case "get_peers":
+			addr, infoHash, ok := parseAddressAndInfoHash(args)
+			if !ok {
+				continue
+			}
+
+			result, err := node.ClosestIdentity(infoHash).GetPeers(*addr, infoHash)
+			if err != nil {
+				fmt.Println("get_peers failed:", err)
+				continue
+			}
+			for _, peer := range result.Peers {
+				fmt.Println("peer", &peer)
+			}
+			printNodes(result.Nodes)
+
+		case "announce":
+			if len(args) != 3 {
+				printUsage()
+				continue
+			}
+
+			addr, infoHash, ok := parseAddressAndInfoHash(args[:2])
+			if !ok {
+				continue
+			}
+
+			port, err := strconv.Atoi(args[2])
+			if err != nil {
+				fmt.Println("invalid port:", err)
+				continue
+			}
+
+			var identity = node.ClosestIdentity(infoHash)
+			result, err := identity.GetPeers(*addr, infoHash)
+			if err != nil {
+				fmt.Println("get_peers failed:", err)
+				continue
+			}
+
+			if err := identity.Announce(*addr, infoHash, port, result.Token); err != nil {
+				fmt.Println("announce failed:", err)
+				continue
+			}
+			fmt.Println("announced")
+
+		case "get":
+			addr, target, ok := parseAddressAndId(args)
+			if !ok {
+				continue
+			}
+
+			result, err := node.ClosestIdentity(target).Get(*addr, target, "")
+			if err != nil {
+				fmt.Println("get failed:", err)
+				continue
+			}
+			if result.Item != nil {
+				fmt.Println(result.Item)
+			}
+			printNodes(result.Nodes)
+
+		case "put":
+			if len(args) != 2 {
+				printUsage()
+				continue
+			}
+
+			addr, err := net.ResolveUDPAddr("udp", args[0])
+			if err != nil {
+				fmt.Println("invalid address:", err)
+				continue
+			}
+
+			var item = dht.NewImmutableItem(bencode.String(args[1]))
+			var identity = node.ClosestIdentity(item.Target())
+			result, err := identity.Get(*addr, item.Target(), "")
+			if err != nil {
+				fmt.Println("get failed:", err)
+				continue
+			}
+
+			if err := identity.Put(*addr, item, result.Token); err != nil {
+				fmt.Println("put failed:", err)
+				continue
+			}
+			fmt.Println("stored under", item.Target())
+
+		default:
+			printUsage()
+		}
+	}
+}
+
+func printInfoHash(args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage:", os.Args[0], "infohash <file.torrent>")
+		os.Exit(1)
+	}
+
+	metaInfo, err := metainfo.Load(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(metaInfo.InfoHash)
+}
+
+// convertBencode pretty-prints the bencoded values in the given files (or stdin) as JSON, or with -encode converts
+// JSON to bencode.
+func convertBencode(args []string) {
+	var flags = flag.NewFlagSet("bencode", flag.ExitOnError)
+	var useBase64 = flags.Bool("base64", false, "show binary strings as base64 instead of hex")
+	var encode = flags.Bool("encode", false, "convert JSON to bencode")
+	flags.Parse(args)
+
+	var binary = bencode.BinaryHex
+	if *useBase64 {
+		binary = bencode.BinaryBase64
+	}
+
+	var inputs []io.Reader
+	for _, name := range flags.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		inputs = append(inputs, file)
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, os.Stdin)
+	}
+
+	for _, input := range inputs {
+		if *encode {
+			data, err := io.ReadAll(input)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			value, err := bencode.FromJSON(data)
+			if err != nil {
+				log.Fatal(err)
+			}
+			os.Stdout.Write(bencode.Append(nil, value))
+			continue
+		}
+
+		var decoder = bencode.NewDecoder(input)
+		for {
+			value, err := decoder.DecodeValue()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				log.Fatal(err)
+			}
+
+			data, err := bencode.ToJSON(value, binary)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			// Indenting only fails on invalid JSON, in which case the partial output is worthless.
+			var pretty bytes.Buffer
+			if err := json.Indent(&pretty, data, "", "  "); err != nil {
+				fmt.Println(string(data))
+				continue
+			}
+			fmt.Println(pretty.String())
+		}
+	}
+}
+
+// crawlNetwork maps the DHT starting from the given bootstrap nodes and writes every responding node to a file or
+// stdout, until no new nodes are found, a limit is reached or it is interrupted.
+func crawlNetwork(args []string) {
+	var flags = flag.NewFlagSet("crawl", flag.ExitOnError)
+	var listen = flags.String("listen", ":0", "address to send queries from")
+	var rate = flags.Float64("rate", dht.DefaultCrawlRate, "nodes to query per second")
+	var workers = flags.Int("workers", dht.DefaultQueryWorkers, "nodes to query at the same time")
+	var maxNodes = flags.Int("max", 0, "stop after this many nodes responded (0 means no limit)")
+	var duration = flags.Duration("duration", 0, "stop after this long (0 means no limit)")
+	var samples = flags.Bool("samples", false, "also ask nodes for samples of their infohashes (BEP 51)")
+	var format = flags.String("format", string(dht.CrawlJSONL), "output format, jsonl or csv")
+	var outputFile = flags.String("o", "", "file to write to instead of stdout")
+	var verbose = flags.Bool("v", false, "log failed queries to stderr")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Println("usage:", os.Args[0], "crawl [-rate n] [-workers n] [-max n] [-duration d] [-samples] [-format jsonl|csv] [-o file] <ip:port>...")
+		os.Exit(1)
+	}
+
+	var bootstrap []net.UDPAddr
+	for _, arg := range flags.Args() {
+		address, err := net.ResolveUDPAddr("udp", arg)
+		if err != nil {
+			log.Fatalf("error resolving bootstrap node %s: %s", arg, err)
+		}
+		bootstrap = append(bootstrap, *address)
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputFile != "" {
+		file, err := os.Create(*outputFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	writer, err := dht.NewCrawlWriter(output, dht.CrawlFormat(*format))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var config = dht.Config{ListenAddress: *listen}
+	// Crawling local test nodes needs local contacts to be accepted, as when running on a local address.
+	for _, address := range bootstrap {
+		config.AddressPolicy.AllowLoopback = config.AddressPolicy.AllowLoopback || address.IP.IsLoopback()
+		config.AddressPolicy.AllowPrivate = config.AddressPolicy.AllowPrivate || address.IP.IsPrivate()
+	}
+	if *verbose {
+		config.Logger = log.New(os.Stderr, "", 0)
+	}
+	node, err := dht.NewNode(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := node.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer node.Close()
+
+	// Stop on Ctrl-C or when the duration is over, keeping what was written so far.
+	var done = make(chan struct{})
+	var interrupt = make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+	go func() {
+		select {
+		case <-interrupt:
+		case <-timeout:
+		}
+		close(done)
+	}()
+
+	var crawlConfig = dht.CrawlConfig{Rate: *rate, Workers: *workers, MaxNodes: *maxNodes, SampleInfohashes: *samples}
+	var stats = node.Crawl(crawlConfig, bootstrap, done, func(crawled dht.CrawledNode) {
+		if err := writer.Write(crawled); err != nil {
+			log.Fatal(err)
+		}
+	})
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprintln(os.Stderr, "queried", stats.Queried, "nodes,", stats.Responded, "responded,", stats.Discovered, "discovered")
+}
+
+// exportRoutingTable writes a snapshot of the routing table in the format given by args[0], to the file args[1] or
+// stdout.
+func exportRoutingTable(node *dht.Node, args []string) error {
+	if len(args) > 2 || (args[0] != "json" && args[0] != "dot") {
+		printUsage()
+		return nil
+	}
+
+	var output io.Writer = os.Stdout
+	if len(args) == 2 {
+		file, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+
+	var snapshot = node.RoutingTableSnapshot()
+	if args[0] == "dot" {
+		return snapshot.WriteDOT(output)
+	}
+	return snapshot.WriteJSON(output)
+}
+
+func parseAddressAndId(args []string) (*net.UDPAddr, dht.NodeId, bool) {
+	if len(args) != 2 {
+		printUsage()
+		return nil, dht.NodeId{}, false
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", args[0])
+	if err != nil {
+		fmt.Println("invalid address:", err)
+		return nil, dht.NodeId{}, false
+	}
+
+	id, err := dht.ParseNodeId(args[1])
+	if err != nil {
+		fmt.Println("invalid id:", err)
+		return nil, dht.NodeId{}, false
+	}
+
+	return addr, id, true
+}
+
+// parseAddressAndInfoHash is like parseAddressAndId, but also accepts a magnet link instead of the id.
+func parseAddressAndInfoHash(args []string) (*net.UDPAddr, dht.NodeId, bool) {
+	if len(args) != 2 || !strings.HasPrefix(args[1], "magnet:") {
+		return parseAddressAndId(args)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", args[0])
+	if err != nil {
+		fmt.Println("invalid address:", err)
+		return nil, dht.NodeId{}, false
+	}
+
+	magnet, err := metainfo.ParseMagnet(args[1])
+	if err != nil {
+		fmt.Println("invalid magnet link:", err)
+		return nil, dht.NodeId{}, false
+	}
+
+	return addr, dht.NodeId(magnet.InfoHash), true
+}
+
+func printNodes(nodes []dht.NodeInfo) {
+	for _, node := range nodes {
+		fmt.Println("node", node.NodeId, &node.Address)
+	}
+}
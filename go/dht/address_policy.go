@@ -0,0 +1,37 @@
+package dht
+
+import (
+	"fmt"
+	"net"
+)
+
+// AddressPolicy decides which addresses are plausible for contacts learned from other nodes. Addresses that can never
+// be reached over the internet are always rejected: port 0, unspecified, broadcast and multicast addresses. So are
+// IPv6 addresses, which the compact node info of BEP 5 has no room for. Loopback and private addresses (including
+// link-local ones) are rejected unless allowed, which is mostly useful for tests and local networks.
+type AddressPolicy struct {
+	AllowLoopback bool
+	AllowPrivate  bool
+}
+
+var thisNetwork = net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(8, 32)}
+
+// check returns why address is rejected, or nil if it is acceptable.
+func (p AddressPolicy) check(address net.UDPAddr) error {
+	var ip = address.IP
+	switch {
+	case address.Port <= 0 || address.Port > 65535:
+		return fmt.Errorf("invalid port %d", address.Port)
+	case ip == nil || ip.IsUnspecified() || thisNetwork.Contains(ip):
+		return fmt.Errorf("unspecified address %s", ip)
+	case ip.To4() == nil:
+		return fmt.Errorf("IPv6 address %s", ip)
+	case ip.Equal(net.IPv4bcast) || ip.IsMulticast():
+		return fmt.Errorf("broadcast or multicast address %s", ip)
+	case ip.IsLoopback() && !p.AllowLoopback:
+		return fmt.Errorf("loopback address %s", ip)
+	case (ip.IsPrivate() || ip.IsLinkLocalUnicast()) && !p.AllowPrivate:
+		return fmt.Errorf("private address %s", ip)
+	}
+	return nil
+}
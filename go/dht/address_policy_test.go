@@ -0,0 +1,97 @@
+package dht
+
+import (
+	"net"
+	"testing"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+func TestAddressPolicy(t *testing.T) {
+	var cases = map[string][3]bool{
+		// accepted by default, with AllowLoopback, with AllowPrivate
+		"1.2.3.4:6881":          {true, true, true},
+		"[2001:db8::1]:6881":    {false, false, false},
+		"1.2.3.4:0":             {false, false, false},
+		"0.0.0.0:6881":          {false, false, false},
+		"0.1.2.3:6881":          {false, false, false},
+		"[::]:6881":             {false, false, false},
+		"255.255.255.255:6881":  {false, false, false},
+		"224.0.0.1:6881":        {false, false, false},
+		"[ff02::1]:6881":        {false, false, false},
+		"127.0.0.1:6881":        {false, true, false},
+		"[::1]:6881":            {false, false, false},
+		"192.168.0.10:6881":     {false, false, true},
+		"10.1.2.3:6881":         {false, false, true},
+		"169.254.1.1:6881":      {false, false, true},
+		"[fd00::1]:6881":        {false, false, false},
+		"[::ffff:10.0.0.1]:1":   {false, false, true},
+		"[::ffff:127.0.0.1]:80": {false, true, false},
+	}
+
+	var policies = []AddressPolicy{{}, {AllowLoopback: true}, {AllowPrivate: true}}
+	for address, expected := range cases {
+		var addr, err = net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, policy := range policies {
+			if err := policy.check(*addr); (err == nil) != expected[i] {
+				t.Error("Expected", address, "to be accepted by", policy, expected[i], "got", err)
+			}
+		}
+	}
+}
+
+func TestNodeRejectsIPv6Contacts(t *testing.T) {
+	var node = startTestNode(t, "0000000000000000000000000000000000000001")
+	var query = func(id string, source net.UDPAddr) krpc.Message {
+		return node.handleQuery(&krpc.Query{MethodName: "find_node", Arguments: bencode.Dict{
+			"id":     bencode.String(id),
+			"target": bencode.String("00000000000000000000"),
+		}}, &source)
+	}
+
+	query("66666666666666666666", net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 6881})
+	query("44444444444444444444", net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 6881})
+	var response = query("44444444444444444444", net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 6881})
+
+	// Only the IPv4 node made it into the routing table, so the response holds exactly one 26 byte contact.
+	var nodes, _ = response.(*krpc.Response).ReturnValues["nodes"].(bencode.String)
+	if len(nodes) != 26 || nodes[:20] != "44444444444444444444" {
+		t.Errorf("Expected only the IPv4 contact in the response, got %q", nodes)
+	}
+}
+
+func TestNodeRejectsLoopbackContacts(t *testing.T) {
+	var a, err = NewNode(Config{ListenAddress: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	var b = startTestNode(t, "8000000000000000000000000000000000000001")
+	if _, err := a.Ping(b.Address()); err != nil {
+		t.Fatal("Expected ping to succeed, got", err)
+	}
+
+	// b only knows itself, at a loopback address.
+	nodes, err := a.FindNode(b.Address(), b.Id())
+	if err != nil || len(nodes) != 0 {
+		t.Error("Expected the loopback contact to be left out, got", nodes, "err:", err)
+	}
+
+	// b itself is rejected as the responder of both queries, and as the contact returned by find_node.
+	if a.RejectedContacts() != 3 {
+		t.Error("Expected 3 rejected contacts, got", a.RejectedContacts())
+	}
+
+	if nodes, _ := a.routingTable.findNodeWithoutSelf(b.Id()); len(nodes) != 0 {
+		t.Error("Expected the pinged node to stay out of the routing table, got", nodes)
+	}
+}
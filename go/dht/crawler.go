@@ -0,0 +1,265 @@
+package dht
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"dhtcli/krpc"
+)
+
+const DefaultCrawlRate = 20
+
+// CrawlConfig holds the settings for Crawl. Zero values are replaced with sensible defaults.
+type CrawlConfig struct {
+	// Rate is how many nodes are queried per second, see DefaultCrawlRate.
+	Rate float64
+	// Workers is how many nodes are queried at the same time, see DefaultQueryWorkers.
+	Workers int
+	// MaxNodes stops the crawl after this many nodes responded. Zero means no limit.
+	MaxNodes int
+	// SampleInfohashes also asks every responding node for samples of its infohashes, as in BEP 51.
+	SampleInfohashes bool
+}
+
+// CrawledNode is a node that responded during a crawl.
+type CrawledNode struct {
+	NodeId  NodeId
+	Address net.UDPAddr
+	// Version is the client version the node sent along with its response, if any.
+	Version   string
+	RoundTrip time.Duration
+	// SamplesSupported tells whether the node answered sample_infohashes. Samples are the infohashes it returned,
+	// Infohashes is the number it stores in total.
+	SamplesSupported bool
+	Samples          []NodeId
+	Infohashes       int
+}
+
+type CrawlStats struct {
+	// Queried is the number of nodes a find_node query was sent to, Responded the number that answered it.
+	Queried   int
+	Responded int
+	// Discovered is the number of distinct nodes learned from responses, whether or not they were queried yet.
+	Discovered int
+}
+
+// crawlOutcome is what a worker reports back about one queried node.
+type crawlOutcome struct {
+	node     *CrawledNode
+	contacts []NodeInfo
+}
+
+// Crawl maps the network, starting from the bootstrap addresses: every node it learns of is asked for the contacts
+// closest to a random target, which spreads the crawl over the whole id space. Nodes are deduplicated by id and by
+// address, and each responding node is passed to found. The crawl ends when there are no more new nodes, MaxNodes is
+// reached or done is closed.
+func (n *Node) Crawl(config CrawlConfig, bootstrap []net.UDPAddr, done <-chan struct{}, found func(CrawledNode)) CrawlStats {
+	var rate = config.Rate
+	if rate <= 0 {
+		rate = DefaultCrawlRate
+	}
+	var workers = config.Workers
+	if workers <= 0 {
+		workers = DefaultQueryWorkers
+	}
+
+	var stats CrawlStats
+	var queue = append([]net.UDPAddr(nil), bootstrap...)
+	var seenIds = make(map[NodeId]bool)
+	var seenAddresses = make(map[string]bool)
+	var responded = make(map[NodeId]bool)
+	for _, address := range bootstrap {
+		seenAddresses[address.String()] = true
+	}
+
+	// Workers never block on reporting, even after Crawl returned, since there are at most that many in flight.
+	var outcomes = make(chan crawlOutcome, workers)
+	var inFlight = 0
+	var interval = time.Duration(float64(time.Second) / rate)
+	var next = time.Now()
+
+	for len(queue) > 0 || inFlight > 0 {
+		// Only wait for the throttle when there is room to start another query.
+		var throttle <-chan time.Time
+		if len(queue) > 0 && inFlight < workers {
+			throttle = time.After(time.Until(next))
+		}
+
+		select {
+		case <-done:
+			return stats
+		case <-throttle:
+			var address = queue[0]
+			queue = queue[1:]
+			inFlight++
+			stats.Queried++
+			if now := time.Now(); next.Before(now) {
+				next = now
+			}
+			next = next.Add(interval)
+			go func() { outcomes <- n.crawlNode(address, config.SampleInfohashes) }()
+		case outcome := <-outcomes:
+			inFlight--
+
+			for _, contact := range outcome.contacts {
+				var address = contact.Address.String()
+				if seenIds[contact.NodeId] || seenAddresses[address] || n.virtualNodes.isOwnId(contact.NodeId) {
+					continue
+				}
+				seenIds[contact.NodeId] = true
+				seenAddresses[address] = true
+				stats.Discovered++
+				queue = append(queue, contact.Address)
+			}
+
+			if outcome.node == nil || responded[outcome.node.NodeId] {
+				continue
+			}
+			responded[outcome.node.NodeId] = true
+			seenIds[outcome.node.NodeId] = true
+			stats.Responded++
+			found(*outcome.node)
+
+			if config.MaxNodes > 0 && stats.Responded >= config.MaxNodes {
+				return stats
+			}
+		}
+	}
+
+	return stats
+}
+
+// crawlNode queries the node at address for the contacts it knows, and for samples of its infohashes if asked to.
+func (n *Node) crawlNode(address net.UDPAddr, sampleInfohashes bool) crawlOutcome {
+	var nodes, info, err = n.findNode(address, randomIdWithPrefix(NodeId{}, 0))
+	if err != nil {
+		n.logger.Println("Crawling", &address, "failed:", err)
+		return crawlOutcome{}
+	}
+
+	var node = CrawledNode{NodeId: info.id, Address: address, Version: info.version, RoundTrip: info.roundTrip}
+	if sampleInfohashes {
+		var result, err = n.SampleInfohashes(address, randomIdWithPrefix(NodeId{}, 0))
+		var krpcErr *krpc.Error
+		if err == nil {
+			node.SamplesSupported = true
+			node.Samples = result.Samples
+			node.Infohashes = result.Infohashes
+			nodes = append(nodes, result.Nodes...)
+		} else if !errors.As(err, &krpcErr) || krpcErr.Code != krpc.ErrorUnknownMethod {
+			n.logger.Println("Sampling infohashes of", &address, "failed:", err)
+		}
+	}
+
+	return crawlOutcome{node: &node, contacts: nodes}
+}
+
+type CrawlFormat string
+
+const (
+	CrawlJSONL CrawlFormat = "jsonl"
+	CrawlCSV   CrawlFormat = "csv"
+)
+
+// CrawlWriter writes crawled nodes as JSON lines or CSV rows.
+type CrawlWriter struct {
+	json *json.Encoder
+	csv  *csv.Writer
+}
+
+var crawlCSVHeader = []string{"id", "address", "version", "rtt_ms", "sample_infohashes", "infohashes", "samples"}
+
+// crawlRecord is the JSON form of a CrawledNode.
+type crawlRecord struct {
+	Id               string   `json:"id"`
+	Address          string   `json:"address"`
+	Version          string   `json:"version,omitempty"`
+	RoundTripMillis  float64  `json:"rtt_ms"`
+	SampleInfohashes bool     `json:"sample_infohashes"`
+	Infohashes       int      `json:"infohashes,omitempty"`
+	Samples          []string `json:"samples,omitempty"`
+}
+
+func NewCrawlWriter(w io.Writer, format CrawlFormat) (*CrawlWriter, error) {
+	switch format {
+	case CrawlJSONL:
+		return &CrawlWriter{json: json.NewEncoder(w)}, nil
+	case CrawlCSV:
+		var writer = &CrawlWriter{csv: csv.NewWriter(w)}
+		if err := writer.csv.Write(crawlCSVHeader); err != nil {
+			return nil, fmt.Errorf("writing CSV header: %w", err)
+		}
+		return writer, nil
+	default:
+		return nil, fmt.Errorf("unknown crawl output format %q", format)
+	}
+}
+
+func (w *CrawlWriter) Write(node CrawledNode) error {
+	var record = crawlRecord{
+		Id:               node.NodeId.String(),
+		Address:          node.Address.String(),
+		Version:          formatVersion(node.Version),
+		RoundTripMillis:  float64(node.RoundTrip.Microseconds()) / 1000,
+		SampleInfohashes: node.SamplesSupported,
+		Infohashes:       node.Infohashes,
+	}
+	for _, sample := range node.Samples {
+		record.Samples = append(record.Samples, sample.String())
+	}
+
+	if w.json != nil {
+		if err := w.json.Encode(record); err != nil {
+			return fmt.Errorf("writing crawled node: %w", err)
+		}
+		return nil
+	}
+
+	var row = []string{
+		record.Id,
+		record.Address,
+		record.Version,
+		strconv.FormatFloat(record.RoundTripMillis, 'f', 3, 64),
+		strconv.FormatBool(record.SampleInfohashes),
+		strconv.Itoa(record.Infohashes),
+		strings.Join(record.Samples, " "),
+	}
+	if err := w.csv.Write(row); err != nil {
+		return fmt.Errorf("writing crawled node: %w", err)
+	}
+	return nil
+}
+
+// Flush writes buffered rows to the underlying writer.
+func (w *CrawlWriter) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// formatVersion makes a client version printable. Most clients send two letters followed by two binary bytes, which
+// are written in hex, e.g. "UT-b356".
+func formatVersion(version string) string {
+	var printable = func(s string) bool {
+		return !strings.ContainsFunc(s, func(r rune) bool { return r < ' ' || r > '~' })
+	}
+
+	switch {
+	case printable(version):
+		return version
+	case len(version) > 2 && printable(version[:2]):
+		return version[:2] + "-" + hex.EncodeToString([]byte(version[2:]))
+	default:
+		return hex.EncodeToString([]byte(version))
+	}
+}
@@ -0,0 +1,106 @@
+package dht
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+func TestCrawl(t *testing.T) {
+	var crawler = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b = startTestNode(t, "4000000000000000000000000000000000000001")
+	var c = startTestNode(t, "8000000000000000000000000000000000000001")
+	var d = startTestNode(t, "c000000000000000000000000000000000000001")
+	var infoHash, _ = hexStringToNodeId("1234567890123456789012345678901234567890")
+
+	// b only knows c, and only c knows d. c stores peers for one infohash.
+	b.Ping(c.Address())
+	c.Ping(d.Address())
+	c.peers.addPeer(infoHash, net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234})
+
+	var found = make(map[NodeId]CrawledNode)
+	var config = CrawlConfig{Rate: 1000, SampleInfohashes: true}
+	var stats = crawler.Crawl(config, []net.UDPAddr{b.Address()}, nil, func(node CrawledNode) {
+		found[node.NodeId] = node
+	})
+
+	if len(found) != 3 || stats.Responded != 3 || stats.Queried != 3 {
+		t.Fatal("Expected to find b, c and d but not ourselves, got", found, stats)
+	}
+	for _, node := range []*Node{b, c, d} {
+		if crawled := found[node.Id()]; crawled.Address.Port != node.Address().Port || crawled.RoundTrip <= 0 || !crawled.SamplesSupported {
+			t.Error("Expected", node.Id(), "to be crawled with its address and round trip, got", crawled)
+		}
+	}
+	if crawled := found[c.Id()]; crawled.Infohashes != 1 || len(crawled.Samples) != 1 || crawled.Samples[0] != infoHash {
+		t.Error("Expected the infohash stored on c to be sampled, got", crawled)
+	}
+
+	// BEP 51 requires interval and num even from a node that stores no infohashes.
+	var source = crawler.Address()
+	var response = d.handleQuery(&krpc.Query{MethodName: "sample_infohashes", Arguments: bencode.Dict{
+		"id":     bencode.String("00000000000000000000"),
+		"target": bencode.String(infoHash[:]),
+	}}, &source)
+	var values = response.(*krpc.Response).ReturnValues
+	if values["num"] != bencode.Int(0) || values["interval"] == nil {
+		t.Error("Expected num and interval in an empty sample_infohashes response, got", values)
+	}
+
+	// Crawling stops after MaxNodes, and when done is closed.
+	stats = crawler.Crawl(CrawlConfig{MaxNodes: 1}, []net.UDPAddr{b.Address()}, nil, func(CrawledNode) {})
+	if stats.Responded != 1 {
+		t.Error("Expected the crawl to stop after one node, got", stats)
+	}
+
+	var done = make(chan struct{})
+	close(done)
+	stats = crawler.Crawl(CrawlConfig{}, []net.UDPAddr{b.Address()}, done, func(CrawledNode) {})
+	if stats.Responded != 0 {
+		t.Error("Expected a stopped crawl to find nothing, got", stats)
+	}
+}
+
+func TestCrawlWriter(t *testing.T) {
+	var node = CrawledNode{
+		NodeId:           NodeId{0xab},
+		Address:          net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 6881},
+		Version:          "UT\xb3\x56",
+		RoundTrip:        1500 * time.Microsecond,
+		SamplesSupported: true,
+		Samples:          []NodeId{{0x01}},
+		Infohashes:       7,
+	}
+
+	var buffer bytes.Buffer
+	var writer, err = NewCrawlWriter(&buffer, CrawlJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(node)
+	writer.Flush()
+	var expected = `{"id":"ab00000000000000000000000000000000000000","address":"1.2.3.4:6881","version":"UT-b356","rtt_ms":1.5,` +
+		`"sample_infohashes":true,"infohashes":7,"samples":["0100000000000000000000000000000000000000"]}` + "\n"
+	if buffer.String() != expected {
+		t.Error("Expected", expected, "got", buffer.String())
+	}
+
+	buffer.Reset()
+	writer, _ = NewCrawlWriter(&buffer, CrawlCSV)
+	writer.Write(CrawledNode{NodeId: NodeId{0xab}, Address: node.Address, Version: "LT0102"})
+	writer.Flush()
+	var lines = strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || lines[0] != "id,address,version,rtt_ms,sample_infohashes,infohashes,samples" ||
+		lines[1] != "ab00000000000000000000000000000000000000,1.2.3.4:6881,LT0102,0.000,false,0," {
+		t.Error("Expected a CSV header and one row, got", lines)
+	}
+
+	if _, err := NewCrawlWriter(&buffer, "xml"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,26 @@
+package dht
+
+import (
+	"testing"
+)
+
+func FuzzDecodeCompactNodeInfo(f *testing.F) {
+	f.Add([]byte("0123456789abcdefghij\x00\x00\x00\x00\x00\x00"))
+	f.Add([]byte("short"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var node, err = decodeCompactNodeInfo(string(data))
+		if err != nil {
+			return
+		}
+
+		if encoded := node.compactNodeInfo(); encoded != string(data) {
+			t.Fatalf("Expected %q to round-trip, got %q", data, encoded)
+		}
+
+		decoded, err := decodeCompactNodeInfos(string(data))
+		if err != nil || len(decoded) != 1 || decoded[0].NodeId != node.NodeId {
+			t.Fatalf("Expected %q to decode as a list of one node, got %v, err: %v", data, decoded, err)
+		}
+	})
+}
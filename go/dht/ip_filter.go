@@ -0,0 +1,316 @@
+package dht
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const blocklistReloadInterval = 10 * time.Second
+
+// IPFilter keeps nodes at blocked addresses out of the routing table, and drops packets from them. An address is
+// blocked if it is in a blocked range and not in an allowed range, so allowed ranges are exceptions to the blocklist.
+//
+// Ranges are given in CIDR notation ("10.0.0.0/8", "fc00::/7"), as a single address, or as a range of addresses
+// ("10.0.0.0-10.0.0.255"). A blocklist file in the P2P format, with lines like "Some description:1.2.3.0-1.2.3.255",
+// can be loaded in addition and is reloaded whenever it changes while a node using the filter is running.
+type IPFilter struct {
+	blocked  ipRanges
+	allowed  ipRanges
+	fromFile ipRanges
+
+	file     string
+	fileInfo os.FileInfo
+	lock     sync.RWMutex
+
+	// watchers counts the running nodes using the filter, which share a single goroutine reloading the blocklist.
+	watchers     int
+	stopWatching chan struct{}
+
+	dropped atomic.Uint64
+}
+
+func NewIPFilter() *IPFilter {
+	return &IPFilter{}
+}
+
+// Block adds a range of blocked addresses.
+func (f *IPFilter) Block(addresses string) error {
+	var r, err = parseIPRange(addresses)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.blocked = append(f.blocked, r).normalize()
+	return nil
+}
+
+// Allow adds a range of addresses that are not blocked, even if the blocklist contains them.
+func (f *IPFilter) Allow(addresses string) error {
+	var r, err = parseIPRange(addresses)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.allowed = append(f.allowed, r).normalize()
+	return nil
+}
+
+// LoadFile loads a blocklist file, replacing the one loaded before.
+func (f *IPFilter) LoadFile(name string) error {
+	var file, err = os.Open(name)
+	if err != nil {
+		return fmt.Errorf("opening blocklist: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("opening blocklist: %w", err)
+	}
+
+	ranges, err := parseBlocklist(file)
+	if err != nil {
+		return fmt.Errorf("reading blocklist %s: %w", name, err)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.fromFile = ranges
+	f.file = name
+	f.fileInfo = info
+	return nil
+}
+
+// reloadIfChanged loads the blocklist file again if its size or modification time changed, and returns its name.
+func (f *IPFilter) reloadIfChanged() (name string, reloaded bool, err error) {
+	f.lock.RLock()
+	var loaded os.FileInfo
+	name, loaded = f.file, f.fileInfo
+	f.lock.RUnlock()
+
+	if name == "" {
+		return "", false, nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return name, false, fmt.Errorf("checking blocklist: %w", err)
+	} else if info.Size() == loaded.Size() && info.ModTime().Equal(loaded.ModTime()) {
+		return name, false, nil
+	}
+
+	return name, true, f.LoadFile(name)
+}
+
+// watch reloads the blocklist file when it changes, until done is closed. Nodes sharing the filter share one watcher,
+// which logs to the logger of the node that started it and runs until all of them are done. A blocklist that fails to
+// load keeps the previous one in place.
+func (f *IPFilter) watch(interval time.Duration, done <-chan struct{}, logger *log.Logger) {
+	f.lock.Lock()
+	f.watchers++
+	if f.watchers == 1 {
+		f.stopWatching = make(chan struct{})
+		go f.reloadPeriodically(interval, f.stopWatching, logger)
+	}
+	f.lock.Unlock()
+
+	go func() {
+		<-done
+
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.watchers--
+		if f.watchers == 0 {
+			close(f.stopWatching)
+		}
+	}()
+}
+
+func (f *IPFilter) reloadPeriodically(interval time.Duration, stop <-chan struct{}, logger *log.Logger) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if name, reloaded, err := f.reloadIfChanged(); err != nil {
+				logger.Println(err)
+			} else if reloaded {
+				logger.Println("Reloaded blocklist", name)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Blocked reports whether ip is blocked. A nil filter blocks nothing.
+func (f *IPFilter) Blocked(ip net.IP) bool {
+	if f == nil {
+		return false
+	}
+
+	var addr, ok = netip.AddrFromSlice(ip)
+	if !ok {
+		return true
+	}
+	addr = addr.Unmap()
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return (f.blocked.contains(addr) || f.fromFile.contains(addr)) && !f.allowed.contains(addr)
+}
+
+// drop reports whether a packet from ip should be dropped, and counts it if so.
+func (f *IPFilter) drop(ip net.IP) bool {
+	if !f.Blocked(ip) {
+		return false
+	}
+
+	f.dropped.Add(1)
+	return true
+}
+
+// Dropped returns the number of packets that were dropped because their source was blocked.
+func (f *IPFilter) Dropped() uint64 {
+	if f == nil {
+		return 0
+	}
+	return f.dropped.Load()
+}
+
+type ipRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+func parseIPRange(s string) (ipRange, error) {
+	s = strings.TrimSpace(s)
+
+	if first, last, found := strings.Cut(s, "-"); found {
+		var r ipRange
+		var err error
+		if r.first, err = netip.ParseAddr(strings.TrimSpace(first)); err != nil {
+			return ipRange{}, fmt.Errorf("parsing address range %q: %w", s, err)
+		} else if r.last, err = netip.ParseAddr(strings.TrimSpace(last)); err != nil {
+			return ipRange{}, fmt.Errorf("parsing address range %q: %w", s, err)
+		}
+
+		r.first, r.last = r.first.Unmap(), r.last.Unmap()
+		if r.first.Is4() != r.last.Is4() || r.last.Less(r.first) {
+			return ipRange{}, fmt.Errorf("parsing address range %q: invalid range", s)
+		}
+		return r, nil
+	}
+
+	if strings.Contains(s, "/") {
+		var prefix, err = netip.ParsePrefix(s)
+		if err != nil {
+			return ipRange{}, fmt.Errorf("parsing address range %q: %w", s, err)
+		}
+		return prefixRange(prefix.Masked()), nil
+	}
+
+	var addr, err = netip.ParseAddr(s)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("parsing address range %q: %w", s, err)
+	}
+	addr = addr.Unmap()
+	return ipRange{first: addr, last: addr}, nil
+}
+
+// prefixRange returns the range of a masked prefix, whose last address has all host bits set.
+func prefixRange(prefix netip.Prefix) ipRange {
+	var first = prefix.Addr().Unmap()
+	var bytes = first.AsSlice()
+	var hostBits = len(bytes)*8 - prefix.Bits()
+	if first.Is4() && prefix.Addr().Is4In6() {
+		hostBits = 128 - prefix.Bits()
+	}
+
+	for i := len(bytes) - 1; i >= 0 && hostBits > 0; i-- {
+		var bits = min(hostBits, 8)
+		bytes[i] |= byte(1<<bits - 1)
+		hostBits -= bits
+	}
+
+	var last, _ = netip.AddrFromSlice(bytes)
+	return ipRange{first: first, last: last}
+}
+
+// parseBlocklist reads the P2P blocklist format: one "description:first-last" range per line, with empty lines and
+// lines starting with "#" ignored. As the format only has IPv4 ranges, lines without a description are accepted too,
+// which allows IPv6 ranges and CIDR notation.
+func parseBlocklist(r io.Reader) (ipRanges, error) {
+	var ranges ipRanges
+	var scanner = bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		var text = strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var r, err = parseIPRange(text)
+		if i := strings.LastIndex(text, ":"); err != nil && i >= 0 {
+			// Descriptions may contain colons themselves, so the range follows the last one.
+			r, err = parseIPRange(text[i+1:])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranges = append(ranges, r)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranges.normalize(), nil
+}
+
+// ipRanges is sorted by first address and free of overlaps once normalized, which allows a binary search.
+type ipRanges []ipRange
+
+func (r ipRanges) normalize() ipRanges {
+	slices.SortFunc(r, func(a, b ipRange) int { return a.first.Compare(b.first) })
+
+	var merged = r[:0]
+	for _, current := range r {
+		if n := len(merged); n > 0 && merged[n-1].last.Is4() == current.first.Is4() &&
+			(!merged[n-1].last.Less(current.first) || merged[n-1].last.Next() == current.first) {
+			if merged[n-1].last.Less(current.last) {
+				merged[n-1].last = current.last
+			}
+			continue
+		}
+		merged = append(merged, current)
+	}
+	return merged
+}
+
+func (r ipRanges) contains(addr netip.Addr) bool {
+	// Find the last range starting at or before addr.
+	var i, found = slices.BinarySearchFunc(r, addr, func(candidate ipRange, target netip.Addr) int {
+		return candidate.first.Compare(target)
+	})
+	if !found {
+		i--
+	}
+
+	return i >= 0 && !r[i].last.Less(addr)
+}
@@ -0,0 +1,170 @@
+package dht
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIPFilter(t *testing.T) {
+	var filter = NewIPFilter()
+	for _, r := range []string{"10.0.0.0/8", "192.168.1.5", "172.16.0.10 - 172.16.0.20", "fc00::/7", "::ffff:100.64.0.0/106"} {
+		if err := filter.Block(r); err != nil {
+			t.Fatal("Expected", r, "to parse, got", err)
+		}
+	}
+	if err := filter.Allow("10.1.2.0/24"); err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = map[string]bool{
+		"10.0.0.1":        true,
+		"10.255.255.255":  true,
+		"11.0.0.0":        false,
+		"10.1.2.3":        false,
+		"::ffff:10.0.0.1": true,
+		"192.168.1.5":     true,
+		"192.168.1.6":     false,
+		"172.16.0.9":      false,
+		"172.16.0.20":     true,
+		"fd12:3456::1":    true,
+		"fe80::1":         false,
+		"100.127.255.255": true,
+		"100.128.0.0":     false,
+		"2001:db8::a00:1": false,
+	}
+
+	for ip, blocked := range cases {
+		if filter.Blocked(net.ParseIP(ip)) != blocked {
+			t.Error("Expected", ip, "to be blocked:", blocked)
+		}
+	}
+
+	var nilFilter *IPFilter
+	if nilFilter.Blocked(net.ParseIP("10.0.0.1")) {
+		t.Error("Expected a nil filter to block nothing")
+	}
+
+	for _, r := range []string{"10.0.0.0/33", "1.2.3", "1.2.3.4-::1", "1.2.3.4-1.2.3.3"} {
+		if err := filter.Block(r); err == nil {
+			t.Error("Expected", r, "to fail parsing")
+		}
+	}
+}
+
+func TestParseBlocklist(t *testing.T) {
+	var blocklist = "# comment\n\nSome: bad crawler:1.2.3.0-1.2.3.255\nOther:1.2.4.0-1.2.4.10\n2001:db8::/32\n"
+	var ranges, err = parseBlocklist(strings.NewReader(blocklist))
+	if err != nil {
+		t.Fatal("Expected blocklist to parse, got", err)
+	}
+
+	// The adjacent IPv4 ranges are merged.
+	if len(ranges) != 2 || ranges[0].first.String() != "1.2.3.0" || ranges[0].last.String() != "1.2.4.10" {
+		t.Error("Expected 2 ranges, got", ranges)
+	}
+
+	if _, err := parseBlocklist(strings.NewReader("ok:1.2.3.4-1.2.3.5\nbroken line\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Error("Expected an error on line 2, got", err)
+	}
+}
+
+func TestIPFilterReload(t *testing.T) {
+	var name = filepath.Join(t.TempDir(), "blocklist.p2p")
+	if err := os.WriteFile(name, []byte("test:1.2.3.0-1.2.3.255\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var filter = NewIPFilter()
+	if err := filter.LoadFile(name); err != nil {
+		t.Fatal("Expected blocklist to load, got", err)
+	}
+
+	if !filter.Blocked(net.ParseIP("1.2.3.4")) {
+		t.Error("Expected 1.2.3.4 to be blocked")
+	}
+
+	if _, reloaded, err := filter.reloadIfChanged(); reloaded || err != nil {
+		t.Error("Expected unchanged blocklist not to be reloaded, got", reloaded, err)
+	}
+
+	if err := os.WriteFile(name, []byte("test:5.6.7.0-5.6.7.255\nmore:8.8.8.8-8.8.8.8\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if reloadedName, reloaded, err := filter.reloadIfChanged(); !reloaded || err != nil || reloadedName != name {
+		t.Error("Expected changed blocklist to be reloaded, got", reloadedName, reloaded, err)
+	}
+
+	if filter.Blocked(net.ParseIP("1.2.3.4")) || !filter.Blocked(net.ParseIP("5.6.7.8")) {
+		t.Error("Expected the reloaded blocklist to replace the old one")
+	}
+}
+
+func TestIPFilterRoutingTableAndNode(t *testing.T) {
+	var filter = NewIPFilter()
+	filter.Block("127.0.0.0/8")
+
+	var table = newRoutingTable(8, NodeInfo{})
+	table.filter = filter
+	table.addEntry(NodeInfo{NodeId: NodeId{1}, Address: net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: NodeId{2}, Address: net.UDPAddr{IP: net.ParseIP("1.1.1.1"), Port: 1}}, contactResponded)
+	if nodes, _ := table.findNode(NodeId{1}); len(nodes) != 1 || nodes[0].NodeId != (NodeId{2}) {
+		t.Error("Expected only the unblocked node in the routing table, got", nodes)
+	}
+
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b, err = NewNode(Config{ListenAddress: "127.0.0.1:0", IPFilter: filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	var start = time.Now()
+	if _, err := a.Ping(b.Address()); err == nil {
+		t.Error("Expected ping to a node blocking us to time out")
+	} else if time.Since(start) < time.Second {
+		t.Error("Expected a timeout, got", err)
+	}
+
+	if filter.Dropped() != 1 {
+		t.Error("Expected 1 dropped packet, got", filter.Dropped())
+	}
+}
+
+func TestIPFilterSharedWatcher(t *testing.T) {
+	var filter = NewIPFilter()
+	var first, second = make(chan struct{}), make(chan struct{})
+	filter.watch(time.Hour, first, log.New(io.Discard, "", 0))
+	filter.watch(time.Hour, second, log.New(io.Discard, "", 0))
+
+	filter.lock.RLock()
+	var watchers, stop = filter.watchers, filter.stopWatching
+	filter.lock.RUnlock()
+	if watchers != 2 {
+		t.Error("Expected 2 nodes sharing the watcher, got", watchers)
+	}
+
+	// The watcher keeps running until the last node using the filter is done.
+	close(first)
+	select {
+	case <-stop:
+		t.Error("Expected the watcher to keep running for the second node")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(second)
+	select {
+	case <-stop:
+	case <-time.After(time.Second):
+		t.Error("Expected the watcher to stop after the last node")
+	}
+}
@@ -0,0 +1,128 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"crypto/sha1"
+	"fmt"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+const maxItemValueSize = 1000
+const maxItemSaltSize = 64
+
+// Item is a value stored in the DHT as described in BEP 44. Immutable items only have a Value and are addressed by
+// its hash, mutable items are signed with an ed25519 key and addressed by the hash of that key and the salt.
+type Item struct {
+	Value     bencode.Value
+	PublicKey ed25519.PublicKey
+	Signature []byte
+	Seq       int
+	Salt      string
+}
+
+func NewImmutableItem(value bencode.Value) Item {
+	return Item{Value: value}
+}
+
+// NewMutableItem creates an item signed with privateKey. Newer versions of an item need a higher seq.
+func NewMutableItem(value bencode.Value, seq int, salt string, privateKey ed25519.PrivateKey) Item {
+	var item = Item{
+		Value:     value,
+		PublicKey: privateKey.Public().(ed25519.PublicKey),
+		Seq:       seq,
+		Salt:      salt,
+	}
+	item.Signature = ed25519.Sign(privateKey, []byte(item.signedPayload()))
+	return item
+}
+
+func (i Item) IsMutable() bool {
+	return i.PublicKey != nil
+}
+
+// Target is the key the item is stored under in the DHT.
+func (i Item) Target() NodeId {
+	if i.IsMutable() {
+		return NodeId(sha1.Sum(append([]byte(i.PublicKey), i.Salt...)))
+	}
+	return NodeId(sha1.Sum([]byte(i.Value.Encode())))
+}
+
+func (i Item) signedPayload() string {
+	var payload string
+	if i.Salt != "" {
+		payload = "4:salt" + bencode.String(i.Salt).Encode()
+	}
+	return payload + "3:seq" + bencode.Int(i.Seq).Encode() + "1:v" + i.Value.Encode()
+}
+
+func (i Item) verify() bool {
+	return ed25519.Verify(i.PublicKey, []byte(i.signedPayload()), i.Signature)
+}
+
+func (i Item) fields() itemFields {
+	var fields = itemFields{Value: i.Value}
+	if i.IsMutable() {
+		var seq = i.Seq
+		fields.Key = i.PublicKey
+		fields.Signature = i.Signature
+		fields.Seq = &seq
+		fields.Salt = i.Salt
+	}
+	return fields
+}
+
+// itemFromFields builds an item from the fields of a put query (or a get response) and checks its size limits and
+// signature.
+func itemFromFields(fields itemFields) (Item, *krpc.Error) {
+	if fields.Value == nil {
+		return Item{}, &krpc.Error{Code: krpc.ErrorProtocol, Message: "Missing 'v' argument"}
+	}
+
+	if len(fields.Value.Encode()) > maxItemValueSize {
+		return Item{}, &krpc.Error{Code: krpc.ErrorMessageTooBig, Message: "Message (v field) too big"}
+	}
+
+	if fields.Key == nil {
+		return Item{Value: fields.Value}, nil
+	}
+
+	if len(fields.Key) != ed25519.PublicKeySize {
+		return Item{}, &krpc.Error{Code: krpc.ErrorProtocol, Message: "Invalid 'k' argument"}
+	}
+
+	if len(fields.Signature) != ed25519.SignatureSize {
+		return Item{}, &krpc.Error{Code: krpc.ErrorProtocol, Message: "Invalid 'sig' argument"}
+	}
+
+	if fields.Seq == nil {
+		return Item{}, &krpc.Error{Code: krpc.ErrorProtocol, Message: "Missing 'seq' argument"}
+	}
+
+	if len(fields.Salt) > maxItemSaltSize {
+		return Item{}, &krpc.Error{Code: krpc.ErrorSaltTooBig, Message: "Salt (salt field) too big"}
+	}
+
+	var item = Item{
+		Value:     fields.Value,
+		PublicKey: ed25519.PublicKey(fields.Key),
+		Signature: fields.Signature,
+		Seq:       *fields.Seq,
+		Salt:      fields.Salt,
+	}
+
+	if !item.verify() {
+		return Item{}, &krpc.Error{Code: krpc.ErrorInvalidSignature, Message: "Invalid signature"}
+	}
+
+	return item, nil
+}
+
+func (i Item) String() string {
+	if i.IsMutable() {
+		return fmt.Sprintf("Item{target: %s, seq: %d, v: %s}", i.Target(), i.Seq, i.Value)
+	}
+	return fmt.Sprintf("Item{target: %s, v: %s}", i.Target(), i.Value)
+}
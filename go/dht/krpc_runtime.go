@@ -0,0 +1,215 @@
+package dht
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+// messageLimits bound the resources spent on decoding a packet. KRPC messages are shallow and a UDP packet is small, so
+// anything beyond these is not a valid message.
+var messageLimits = bencode.DecodeOptions{MaxDepth: 8, MaxElements: 512, MaxStringLength: 2048}
+
+// ErrQueryTimeout is returned for queries that were not answered within the query timeout.
+var ErrQueryTimeout = errors.New("timeout")
+
+type queryHandler interface {
+	handleQuery(message *krpc.Query, source *net.UDPAddr) krpc.Message
+}
+
+type krpcRuntime struct {
+	pendingRequests     map[string]chan<- krpc.Message
+	pendingRequestsLock sync.Mutex
+	addr                *net.UDPAddr
+	conn                *net.UDPConn
+	queryTimeout        time.Duration
+	logger              *log.Logger
+	limiter             *rateLimiter
+	queries             *queryQueue
+	filter              *IPFilter
+	done                chan struct{}
+	closeOnce           sync.Once
+}
+
+func newKrpcRuntime(listenOn *net.UDPAddr, queryTimeout time.Duration, rateLimit RateLimit, queryQueue QueryQueue,
+	logger *log.Logger) *krpcRuntime {
+	return &krpcRuntime{
+		pendingRequests:     make(map[string]chan<- krpc.Message),
+		pendingRequestsLock: sync.Mutex{},
+		addr:                listenOn,
+		queryTimeout:        queryTimeout,
+		logger:              logger,
+		limiter:             newRateLimiter(rateLimit),
+		queries:             newQueryQueue(queryQueue),
+		done:                make(chan struct{}),
+	}
+}
+
+const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+func (k *krpcRuntime) generateTransactionId() string {
+	// TODO: This should check for uniqueness in the pending requests map and can probably use
+	//       arbitrary bytes instead of ascii characters
+	b := make([]byte, 2)
+	for i := range b {
+		b[i] = letterBytes[rand.Intn(len(letterBytes))]
+	}
+	return string(b)
+}
+
+func (k *krpcRuntime) enqueuePendingRequest() (<-chan krpc.Message, string) {
+	k.pendingRequestsLock.Lock()
+	defer k.pendingRequestsLock.Unlock()
+
+	var ch = make(chan krpc.Message, 1)
+	var transactionId = k.generateTransactionId()
+	k.pendingRequests[transactionId] = ch
+	return ch, transactionId
+}
+
+func (k *krpcRuntime) cancelPendingRequest(id string) {
+	k.pendingRequestsLock.Lock()
+	defer k.pendingRequestsLock.Unlock()
+
+	delete(k.pendingRequests, id)
+}
+
+func (k *krpcRuntime) dequeuePendingRequest(id string) (chan<- krpc.Message, bool) {
+	k.pendingRequestsLock.Lock()
+	defer k.pendingRequestsLock.Unlock()
+
+	ch, ok := k.pendingRequests[id]
+	delete(k.pendingRequests, id)
+	return ch, ok
+}
+
+func (k *krpcRuntime) rpcCall(dest net.UDPAddr, msg krpc.Query) (krpc.Message, error) {
+	var responseChannel, transactionId = k.enqueuePendingRequest()
+	msg.TransactionId = transactionId
+
+	_, err := k.conn.WriteToUDP([]byte(msg.Encode()), &dest)
+	if err != nil {
+		k.cancelPendingRequest(transactionId)
+		return nil, fmt.Errorf("sending KRPC request via UDP to %s: %w", dest.String(), err)
+	}
+
+	select {
+	case msg := <-responseChannel:
+		return msg, nil
+	case <-time.After(k.queryTimeout):
+		k.cancelPendingRequest(transactionId)
+		return nil, ErrQueryTimeout
+	case <-k.done:
+		k.cancelPendingRequest(transactionId)
+		return nil, net.ErrClosed
+	}
+}
+
+func (k *krpcRuntime) receiveMessages() {
+	buffer := make([]byte, 65535)
+
+	for {
+		k.logger.Println("Waiting for messages...")
+
+		bytesReceived, srcAddr, err := k.conn.ReadFromUDP(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			k.logger.Println(err)
+			continue
+		}
+
+		if k.filter.drop(srcAddr.IP) {
+			continue
+		}
+
+		k.logger.Println("Received", bytesReceived, "bytes", "from", srcAddr)
+
+		// TODO: If this is invalid bencode, we should reply with an error response. For now just log and continue
+		dict, err := messageLimits.DecodeDict(string(buffer[:bytesReceived]))
+		if err != nil {
+			k.logger.Println(err)
+			continue
+		}
+
+		// TODO: If this is an invalid KRPC message, we should send an error response. For now, just log and continue
+		msg, err := krpc.DecodeMessage(dict)
+		if err != nil {
+			k.logger.Println(err)
+			continue
+		}
+
+		switch msg.(type) {
+		case *krpc.Query:
+			if !k.limiter.allow(srcAddr.IP) {
+				k.rejectLimitedQuery(msg.(*krpc.Query), srcAddr)
+				continue
+			}
+
+			k.queries.push(queuedQuery{query: msg.(*krpc.Query), source: srcAddr, received: time.Now()})
+		default:
+			var id = msg.GetTransactionId()
+			if ch, ok := k.dequeuePendingRequest(id); ok {
+				ch <- msg
+			} else {
+				// TODO: We got a (error-)response with an unknown transaction ID - either we never sent a corresponding
+				//       request, we already handled an earlier response or the request timed out. Either way, we should
+				//       reply with an error response. For now, log it.
+				k.logger.Println("Received response with unknown transaction ID:", id)
+				k.logger.Println(msg)
+			}
+		}
+	}
+}
+
+func (k *krpcRuntime) handleQuery(handler queryHandler, query queuedQuery) {
+	var response = handler.handleQuery(query.query, query.source)
+	if response != nil {
+		response.SetTransactionId(query.query.TransactionId)
+		_, err := k.conn.WriteToUDP([]byte(response.Encode()), query.source)
+		if err != nil {
+			k.logger.Println(err)
+		}
+	}
+}
+
+// rejectLimitedQuery handles a query over the rate limit, replying with an error if configured.
+func (k *krpcRuntime) rejectLimitedQuery(query *krpc.Query, source *net.UDPAddr) {
+	if !k.limiter.config.Reply {
+		return
+	}
+
+	k.limiter.repliedLimited.Add(1)
+	var response = krpc.Error{TransactionId: query.TransactionId, Code: krpc.ErrorServer, Message: "Rate limit exceeded"}
+	if _, err := k.conn.WriteToUDP([]byte(response.Encode()), source); err != nil {
+		k.logger.Println(err)
+	}
+}
+
+func (k *krpcRuntime) start(handler queryHandler) error {
+	conn, err := net.ListenUDP("udp", k.addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", k.addr, err)
+	}
+
+	k.conn = conn
+	k.addr = conn.LocalAddr().(*net.UDPAddr)
+
+	for range k.queries.config.Workers {
+		go k.queries.work(func(query queuedQuery) { k.handleQuery(handler, query) }, k.done)
+	}
+	go k.receiveMessages()
+	return nil
+}
+
+func (k *krpcRuntime) close() error {
+	k.closeOnce.Do(func() { close(k.done) })
+	return k.conn.Close()
+}
@@ -0,0 +1,129 @@
+package dht
+
+import (
+	"errors"
+	"fmt"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+// Arguments and return values of the queries in BEP 5, BEP 44 and BEP 51, see bencode.Marshal for the tags.
+
+type pingArguments struct {
+	Id NodeId `bencode:"id"`
+}
+
+type findNodeArguments struct {
+	Id     NodeId `bencode:"id"`
+	Target NodeId `bencode:"target"`
+}
+
+type getPeersArguments struct {
+	Id       NodeId `bencode:"id"`
+	InfoHash NodeId `bencode:"info_hash"`
+}
+
+type announcePeerArguments struct {
+	Id          NodeId `bencode:"id"`
+	InfoHash    NodeId `bencode:"info_hash"`
+	Port        int    `bencode:"port,omitempty"`
+	ImpliedPort bool   `bencode:"implied_port,omitempty"`
+	Token       string `bencode:"token"`
+}
+
+type getArguments struct {
+	Id     NodeId `bencode:"id"`
+	Target NodeId `bencode:"target"`
+	Seq    *int   `bencode:"seq,omitempty"`
+}
+
+type sampleInfohashesArguments struct {
+	Id     NodeId `bencode:"id"`
+	Target NodeId `bencode:"target"`
+}
+
+// itemFields are the fields describing a BEP 44 item, shared by put queries and get responses.
+type itemFields struct {
+	Value     bencode.Value `bencode:"v,omitempty"`
+	Key       []byte        `bencode:"k,omitempty"`
+	Signature []byte        `bencode:"sig,omitempty"`
+	Seq       *int          `bencode:"seq,omitempty"`
+	Salt      string        `bencode:"salt,omitempty"`
+}
+
+type putArguments struct {
+	Id    NodeId `bencode:"id"`
+	Token string `bencode:"token"`
+	Cas   *int   `bencode:"cas,omitempty"`
+	itemFields
+}
+
+type pingResponse struct {
+	Id NodeId `bencode:"id"`
+}
+
+// The "nodes" of find_node and get_peers responses are pointers, so that we send them even if we know no contacts,
+// while still telling a response without them from one with an empty list.
+type findNodeResponse struct {
+	Id    NodeId  `bencode:"id"`
+	Nodes *string `bencode:"nodes,omitempty"`
+}
+
+type getPeersResponse struct {
+	Id     NodeId   `bencode:"id"`
+	Token  string   `bencode:"token,omitempty"`
+	Values []string `bencode:"values,omitempty"`
+	Nodes  *string  `bencode:"nodes,omitempty"`
+}
+
+type sampleInfohashesResponse struct {
+	Id       NodeId `bencode:"id"`
+	Interval int    `bencode:"interval"`
+	Nodes    string `bencode:"nodes,omitempty"`
+	Num      int    `bencode:"num"`
+	Samples  string `bencode:"samples"`
+}
+
+type getResponse struct {
+	Id    NodeId `bencode:"id"`
+	Token string `bencode:"token,omitempty"`
+	Nodes string `bencode:"nodes,omitempty"`
+	itemFields
+}
+
+// optionalNodes returns the compact node infos of a response, which are empty if it had none.
+func optionalNodes(nodes *string) string {
+	if nodes == nil {
+		return ""
+	}
+	return *nodes
+}
+
+// decodeArguments unmarshals the arguments of a query, turning validation errors into KRPC protocol errors that
+// name the offending argument.
+func decodeArguments(args bencode.Dict, into any) *krpc.Error {
+	var err = bencode.UnmarshalValue(args, into)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErr *bencode.FieldError
+	if !errors.As(err, &fieldErr) {
+		return &krpc.Error{Code: krpc.ErrorProtocol, Message: "Invalid arguments"}
+	} else if errors.Is(err, bencode.ErrMissingField) {
+		return &krpc.Error{Code: krpc.ErrorProtocol, Message: fmt.Sprintf("Missing '%s' argument", fieldErr.Field)}
+	}
+
+	return &krpc.Error{Code: krpc.ErrorProtocol, Message: fmt.Sprintf("Invalid '%s' argument", fieldErr.Field)}
+}
+
+// makeResponse marshals the return values of a query. They are declared by us, so failing to marshal them is a bug.
+func makeResponse(returnValues any) krpc.Message {
+	var value, err = bencode.MarshalValue(returnValues)
+	if err != nil {
+		panic(err)
+	}
+
+	return &krpc.Response{ReturnValues: value.(bencode.Dict)}
+}
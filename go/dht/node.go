@@ -0,0 +1,717 @@
+// Package dht implements a node of the BitTorrent Mainline DHT (BEP 5), including the BEP 44 storage extension.
+package dht
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+const DefaultBucketSize = 8
+
+const DefaultQueryTimeout = 10 * time.Second
+
+// Config holds the settings for a Node. Zero values are replaced with sensible defaults.
+type Config struct {
+	// ListenAddress is the local UDP address to listen on, e.g. "0.0.0.0:6881".
+	ListenAddress string
+	// NodeId is the id of this node. A random id is generated if it is left zero.
+	NodeId NodeId
+	// BucketSize is k, the number of nodes per bucket and the number of nodes returned by find_node and get_peers.
+	BucketSize         int
+	RoutingTableLayout RoutingTableLayout
+	QueryTimeout       time.Duration
+	// RateLimit limits incoming queries. The zero value does not limit anything.
+	RateLimit RateLimit
+	// QueryQueue bounds the number of queries that are handled or waiting to be handled at the same time.
+	QueryQueue QueryQueue
+	// IPFilter blocks addresses from the routing table and drops packets from them. It may be shared between nodes.
+	IPFilter *IPFilter
+	// AddressPolicy rejects contacts with implausible addresses. By default, loopback and private addresses are
+	// rejected.
+	AddressPolicy AddressPolicy
+	SybilLimits   SybilLimits
+	// OnRoutingEvent is called with suspicious activity around the routing table, e.g. for monitoring. It is called
+	// from the goroutine handling a query or response, so it must not block.
+	OnRoutingEvent func(RoutingEvent)
+	// Logger receives diagnostic output. Nothing is logged if it is nil.
+	Logger *log.Logger
+}
+
+// Node is a single DHT node: it answers queries from other nodes and sends its own queries to them.
+type Node struct {
+	thisNodeInfo NodeInfo
+	routingTable *routingTable
+	krpcRuntime  *krpcRuntime
+	peers        *peerStore
+	items        *itemStore
+	tokens       *tokenManager
+	logger       *log.Logger
+
+	addressPolicy    AddressPolicy
+	rejectedContacts atomic.Uint64
+	onRoutingEvent   func(RoutingEvent)
+	// verifying holds the ids of nodes that are being pinged to verify an address change or as eviction candidate, so
+	// that each is only pinged once at a time.
+	verifying sync.Map
+	// virtualNodes holds all identities sharing krpcRuntime, including this one.
+	virtualNodes *virtualNodes
+}
+
+func NewNode(config Config) (*Node, error) {
+	listenOn, err := net.ResolveUDPAddr("udp", config.ListenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("resolving listen address: %w", err)
+	}
+
+	var ownId = config.NodeId
+	if ownId == (NodeId{}) {
+		ownId, err = RandomNodeId()
+		if err != nil {
+			return nil, fmt.Errorf("generating node id: %w", err)
+		}
+	}
+
+	var bucketSize = config.BucketSize
+	if bucketSize <= 0 {
+		bucketSize = DefaultBucketSize
+	}
+
+	var queryTimeout = config.QueryTimeout
+	if queryTimeout <= 0 {
+		queryTimeout = DefaultQueryTimeout
+	}
+
+	var logger = config.Logger
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	var thisNodeInfo = NodeInfo{
+		NodeId:  ownId,
+		Address: *listenOn,
+	}
+
+	var routingTable = newRoutingTable(bucketSize, thisNodeInfo)
+	routingTable.setLayout(config.RoutingTableLayout)
+	routingTable.filter = config.IPFilter
+	if !config.SybilLimits.Unlimited {
+		routingTable.maxPerIP = cmp.Or(config.SybilLimits.PerIP, DefaultMaxNodesPerIP)
+		routingTable.maxPerPrefix = cmp.Or(config.SybilLimits.PerPrefix, DefaultMaxNodesPerPrefix)
+	}
+
+	var krpcRuntime = newKrpcRuntime(listenOn, queryTimeout, config.RateLimit, config.QueryQueue, logger)
+	krpcRuntime.filter = config.IPFilter
+
+	var node = &Node{
+		thisNodeInfo:   thisNodeInfo,
+		routingTable:   routingTable,
+		krpcRuntime:    krpcRuntime,
+		peers:          newPeerStore(),
+		items:          newItemStore(),
+		tokens:         newTokenManager(),
+		logger:         logger,
+		addressPolicy:  config.AddressPolicy,
+		onRoutingEvent: config.OnRoutingEvent,
+	}
+	node.virtualNodes = &virtualNodes{nodes: []*Node{node}}
+	return node, nil
+}
+
+// Start opens the UDP socket and starts answering queries. It is called on the node returned by NewNode, and also
+// starts the virtual nodes added to it.
+func (n *Node) Start() error {
+	if err := n.krpcRuntime.start(n.virtualNodes); err != nil {
+		return err
+	}
+
+	for _, node := range n.virtualNodes.all() {
+		node.thisNodeInfo.Address = *n.krpcRuntime.addr
+		node.routingTable.setOwnAddress(*n.krpcRuntime.addr)
+	}
+
+	if n.krpcRuntime.filter != nil {
+		n.krpcRuntime.filter.watch(blocklistReloadInterval, n.krpcRuntime.done, n.logger)
+	}
+	go n.sweepStorage(storageSweepInterval, n.krpcRuntime.done)
+	return nil
+}
+
+// sweepStorage regularly drops expired peers and items, which are otherwise only dropped when they are asked for,
+// until done is closed. The virtual nodes share the stores, so this runs once for all of them.
+func (n *Node) sweepStorage(interval time.Duration, done <-chan struct{}) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.peers.removeExpired()
+			n.items.removeExpired()
+		case <-done:
+			return
+		}
+	}
+}
+
+// Close stops the node. Calls that are still waiting for a response fail with net.ErrClosed. Closing a node again only
+// returns an error.
+func (n *Node) Close() error {
+	return n.krpcRuntime.close()
+}
+
+func (n *Node) Id() NodeId {
+	return n.thisNodeInfo.NodeId
+}
+
+// Address returns the address the node is listening on, which is only final after Start.
+func (n *Node) Address() net.UDPAddr {
+	return n.thisNodeInfo.Address
+}
+
+func (n *Node) PrintRoutingTable() {
+	printRoutingTable(n.routingTable)
+}
+
+// RoutingTableStats returns how many good, questionable and bad nodes each bucket holds, and an estimate of the size of
+// the DHT.
+func (n *Node) RoutingTableStats() RoutingTableStats {
+	return n.routingTable.stats()
+}
+
+// RoutingTableSnapshot returns a copy of the routing table that can be exported with WriteJSON or WriteDOT.
+func (n *Node) RoutingTableSnapshot() RoutingTableSnapshot {
+	return n.routingTable.snapshot()
+}
+
+// QueryQueueStats returns how many incoming queries were handled or dropped because the queue was full, and how long
+// handling them took.
+func (n *Node) QueryQueueStats() QueryQueueStats {
+	return n.krpcRuntime.queries.stats()
+}
+
+// RejectedContacts returns the number of contacts that were ignored because their address violates the AddressPolicy.
+func (n *Node) RejectedContacts() uint64 {
+	return n.rejectedContacts.Load()
+}
+
+// acceptContact checks the address of a contact learned from another node against the address policy.
+func (n *Node) acceptContact(contact NodeInfo) bool {
+	if err := n.addressPolicy.check(contact.Address); err != nil {
+		n.rejectedContacts.Add(1)
+		n.logger.Println("Rejected contact", contact.NodeId, "with", err)
+		return false
+	}
+	return true
+}
+
+// addContact adds a node we heard from to the routing table, if its address is acceptable. A known node id at a new
+// address is verified in the background.
+func (n *Node) addContact(contact NodeInfo, kind contactKind) {
+	if n.virtualNodes.isOwnId(contact.NodeId) || !n.acceptContact(contact) {
+		return
+	}
+
+	var result = n.routingTable.addEntry(contact, kind)
+	switch result.event {
+	case EventIPLimit, EventPrefixLimit:
+		n.emitRoutingEvent(RoutingEvent{Type: result.event, Node: contact})
+	case EventAddressChange:
+		n.emitRoutingEvent(RoutingEvent{Type: result.event, Node: contact, PreviousAddress: result.existing.Address})
+		if _, alreadyVerifying := n.verifying.LoadOrStore(contact.NodeId, true); !alreadyVerifying {
+			go n.verifyAddressChange(contact, result.existing.Address)
+		}
+	}
+
+	if candidate := result.evictionCandidate; candidate != nil {
+		if _, alreadyVerifying := n.verifying.LoadOrStore(candidate.NodeId, true); !alreadyVerifying {
+			go n.pingEvictionCandidate(*candidate, contact, kind)
+		}
+	}
+}
+
+// pingEvictionCandidate checks whether the least recently seen node of a full bucket is still around. An answer moves
+// the node to the tail of its bucket and the pending contact is dropped. If it does not answer, it is marked bad right
+// away and the pending contact takes its place.
+func (n *Node) pingEvictionCandidate(candidate NodeInfo, pending NodeInfo, kind contactKind) {
+	defer n.verifying.Delete(candidate.NodeId)
+	if _, err := n.Ping(candidate.Address); errors.Is(err, ErrQueryTimeout) {
+		n.routingTable.markBad(candidate.NodeId)
+		n.addContact(pending, kind)
+	}
+}
+
+// verifyAddressChange moves a node to its new address, unless it still answers at the previous one. In that case the
+// new address is likely someone else using its id.
+func (n *Node) verifyAddressChange(contact NodeInfo, previousAddress net.UDPAddr) {
+	defer n.verifying.Delete(contact.NodeId)
+
+	var event = RoutingEvent{Type: EventAddressChangeAccepted, Node: contact, PreviousAddress: previousAddress}
+	if id, err := n.Ping(previousAddress); err == nil && id == contact.NodeId {
+		event.Type = EventAddressChangeRejected
+	} else {
+		n.routingTable.updateAddress(contact.NodeId, contact.Address)
+	}
+
+	n.emitRoutingEvent(event)
+}
+
+func (n *Node) emitRoutingEvent(event RoutingEvent) {
+	n.logger.Println("Routing table event:", event.Type, event.Node.NodeId, &event.Node.Address)
+	if n.onRoutingEvent != nil {
+		n.onRoutingEvent(event)
+	}
+}
+
+// decodeContacts decodes compact node info, leaving out the contacts that acceptContact rejects.
+func (n *Node) decodeContacts(data string) ([]NodeInfo, error) {
+	var nodes, err = decodeCompactNodeInfos(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var accepted = nodes[:0]
+	for _, node := range nodes {
+		if n.acceptContact(node) {
+			accepted = append(accepted, node)
+		}
+	}
+	return accepted, nil
+}
+
+// RateLimitStats returns how many incoming queries were handled or rejected by the rate limit.
+func (n *Node) RateLimitStats() RateLimitStats {
+	return n.krpcRuntime.limiter.stats()
+}
+
+// Queries we answer
+
+func (n *Node) handlePing(args bencode.Dict, source *net.UDPAddr) krpc.Message {
+	var arguments pingArguments
+	if err := decodeArguments(args, &arguments); err != nil {
+		return err
+	}
+
+	return makeResponse(pingResponse{Id: n.thisNodeInfo.NodeId})
+}
+
+func (n *Node) handleFindNode(args bencode.Dict, source *net.UDPAddr) krpc.Message {
+	var arguments findNodeArguments
+	if err := decodeArguments(args, &arguments); err != nil {
+		return err
+	}
+
+	var nodes, _ = n.routingTable.findNode(arguments.Target)
+	var compactNodes = encodeCompactNodeInfos(nodes)
+
+	return makeResponse(findNodeResponse{
+		Id:    n.thisNodeInfo.NodeId,
+		Nodes: &compactNodes,
+	})
+}
+
+func (n *Node) handleGetPeers(args bencode.Dict, source *net.UDPAddr) krpc.Message {
+	var arguments getPeersArguments
+	if err := decodeArguments(args, &arguments); err != nil {
+		return err
+	}
+
+	var response = getPeersResponse{
+		Id:    n.thisNodeInfo.NodeId,
+		Token: n.tokens.tokenFor(source.IP),
+	}
+
+	if peers := n.peers.getPeers(arguments.InfoHash); len(peers) > 0 {
+		for _, peer := range peers {
+			response.Values = append(response.Values, compactPeerInfo(peer))
+		}
+	} else {
+		var nodes, _ = n.routingTable.findNodeWithoutSelf(arguments.InfoHash)
+		var compactNodes = encodeCompactNodeInfos(nodes)
+		response.Nodes = &compactNodes
+	}
+
+	return makeResponse(response)
+}
+
+func (n *Node) handleAnnouncePeer(args bencode.Dict, source *net.UDPAddr) krpc.Message {
+	var arguments announcePeerArguments
+	if err := decodeArguments(args, &arguments); err != nil {
+		return err
+	}
+
+	if !n.tokens.isValid(arguments.Token, source.IP) {
+		return &krpc.Error{
+			Code:    krpc.ErrorProtocol,
+			Message: "Invalid 'token' argument",
+		}
+	}
+
+	var peer = net.UDPAddr{IP: source.IP, Port: source.Port}
+	if !arguments.ImpliedPort {
+		if arguments.Port <= 0 || arguments.Port > 65535 {
+			return &krpc.Error{
+				Code:    krpc.ErrorProtocol,
+				Message: "Invalid 'port' argument",
+			}
+		}
+		peer.Port = arguments.Port
+	}
+
+	n.peers.addPeer(arguments.InfoHash, peer)
+
+	return makeResponse(pingResponse{Id: n.thisNodeInfo.NodeId})
+}
+
+func (n *Node) handleGet(args bencode.Dict, source *net.UDPAddr) krpc.Message {
+	var arguments getArguments
+	if err := decodeArguments(args, &arguments); err != nil {
+		return err
+	}
+
+	var nodes, _ = n.routingTable.findNodeWithoutSelf(arguments.Target)
+	var response = getResponse{
+		Id:    n.thisNodeInfo.NodeId,
+		Token: n.tokens.tokenFor(source.IP),
+		Nodes: encodeCompactNodeInfos(nodes),
+	}
+
+	if item, ok := n.items.get(arguments.Target); ok {
+		response.itemFields = item.fields()
+		response.Salt = ""
+		// A requester that already knows a recent enough version does not need the value again.
+		if item.IsMutable() && arguments.Seq != nil && *arguments.Seq >= item.Seq {
+			response.Value = nil
+			response.Signature = nil
+		}
+	}
+
+	return makeResponse(response)
+}
+
+func (n *Node) handlePut(args bencode.Dict, source *net.UDPAddr) krpc.Message {
+	var arguments putArguments
+	if err := decodeArguments(args, &arguments); err != nil {
+		return err
+	}
+
+	if !n.tokens.isValid(arguments.Token, source.IP) {
+		return &krpc.Error{
+			Code:    krpc.ErrorProtocol,
+			Message: "Invalid 'token' argument",
+		}
+	}
+
+	item, krpcErr := itemFromFields(arguments.itemFields)
+	if krpcErr != nil {
+		return krpcErr
+	}
+
+	if krpcErr := n.items.put(item, arguments.Cas); krpcErr != nil {
+		return krpcErr
+	}
+
+	return makeResponse(pingResponse{Id: n.thisNodeInfo.NodeId})
+}
+
+func (n *Node) handleSampleInfohashes(args bencode.Dict, source *net.UDPAddr) krpc.Message {
+	var arguments sampleInfohashesArguments
+	if err := decodeArguments(args, &arguments); err != nil {
+		return err
+	}
+
+	var samples, total = n.peers.sample(maxSamples)
+	var nodes, _ = n.routingTable.findNodeWithoutSelf(arguments.Target)
+	var response = sampleInfohashesResponse{
+		Id: n.thisNodeInfo.NodeId,
+		// The samples change as peers expire, so there is no point in asking again sooner.
+		Interval: int(peerExpiry.Seconds()),
+		Nodes:    encodeCompactNodeInfos(nodes),
+		Num:      total,
+	}
+	for _, sample := range samples {
+		response.Samples += string(sample[:])
+	}
+
+	return makeResponse(response)
+}
+
+var handlerFunctions = map[string]func(*Node, bencode.Dict, *net.UDPAddr) krpc.Message{
+	"ping":              (*Node).handlePing,
+	"find_node":         (*Node).handleFindNode,
+	"get_peers":         (*Node).handleGetPeers,
+	"announce_peer":     (*Node).handleAnnouncePeer,
+	"get":               (*Node).handleGet,
+	"put":               (*Node).handlePut,
+	"sample_infohashes": (*Node).handleSampleInfohashes,
+}
+
+func (n *Node) handleQuery(message *krpc.Query, source *net.UDPAddr) krpc.Message {
+	n.logger.Println(message)
+
+	handler, ok := handlerFunctions[message.MethodName]
+	if !ok {
+		return &krpc.Error{
+			Code:    krpc.ErrorUnknownMethod,
+			Message: fmt.Sprintf("Unknown method '%s'", message.MethodName),
+		}
+	}
+
+	var response = handler(n, message.Arguments, source)
+	if _, failed := response.(*krpc.Error); !failed {
+		if id, ok := nodeIdArgument(message.Arguments); ok {
+			n.addContact(NodeInfo{NodeId: id, Address: *source}, contactSeen)
+		}
+	}
+
+	return response
+}
+
+// nodeIdArgument returns the "id" that every query and response carries.
+func nodeIdArgument(args bencode.Dict) (NodeId, bool) {
+	var id, ok = args["id"].(bencode.String)
+	if !ok || len(id) != len(NodeId{}) {
+		return NodeId{}, false
+	}
+	return NodeId([]byte(id)), true
+}
+
+// Queries we send
+
+// replyInfo describes how a node replied to a query.
+type replyInfo struct {
+	id NodeId
+	// version is the "v" key of the response, identifying the client.
+	version   string
+	roundTrip time.Duration
+}
+
+// query sends a query with the given arguments to dest and unmarshals the return values of its response into
+// response, which has to have an "id" field. KRPC error replies are returned as *krpc.Error.
+func (n *Node) query(dest net.UDPAddr, methodName string, arguments any, response any) error {
+	var _, err = n.queryWithInfo(dest, methodName, arguments, response)
+	return err
+}
+
+// queryWithInfo is query, but also tells how the node replied.
+func (n *Node) queryWithInfo(dest net.UDPAddr, methodName string, arguments any, response any) (replyInfo, error) {
+	args, err := bencode.MarshalValue(arguments)
+	if err != nil {
+		return replyInfo{}, fmt.Errorf("marshalling %s arguments: %w", methodName, err)
+	}
+
+	var msg = krpc.Query{
+		MethodName: methodName,
+		Arguments:  args.(bencode.Dict),
+	}
+
+	var sent = time.Now()
+	reply, err := n.krpcRuntime.rpcCall(dest, msg)
+	if errors.Is(err, ErrQueryTimeout) {
+		n.routingTable.markFailed(dest)
+		return replyInfo{}, err
+	} else if err != nil {
+		return replyInfo{}, err
+	}
+	var info = replyInfo{roundTrip: time.Since(sent)}
+
+	switch reply := reply.(type) {
+	case *krpc.Response:
+		info.version = reply.Version
+		if id, ok := nodeIdArgument(reply.ReturnValues); ok {
+			info.id = id
+			n.addContact(NodeInfo{NodeId: id, Address: dest}, contactResponded)
+		}
+
+		if err := bencode.UnmarshalValue(reply.ReturnValues, response); err != nil {
+			return info, fmt.Errorf("invalid %s response: %w", methodName, err)
+		}
+		return info, nil
+	case *krpc.Error:
+		return info, reply
+	default:
+		return info, fmt.Errorf("unexpected reply to %s query: %v", methodName, reply)
+	}
+}
+
+// Ping sends a ping query to dest and returns the node id it replied with.
+func (n *Node) Ping(dest net.UDPAddr) (NodeId, error) {
+	var response pingResponse
+	if err := n.query(dest, "ping", pingArguments{Id: n.thisNodeInfo.NodeId}, &response); err != nil {
+		return NodeId{}, err
+	}
+	return response.Id, nil
+}
+
+// FindNode asks dest for the contacts it knows closest to target.
+func (n *Node) FindNode(dest net.UDPAddr, target NodeId) ([]NodeInfo, error) {
+	var nodes, _, err = n.findNode(dest, target)
+	return nodes, err
+}
+
+func (n *Node) findNode(dest net.UDPAddr, target NodeId) ([]NodeInfo, replyInfo, error) {
+	var arguments = findNodeArguments{Id: n.thisNodeInfo.NodeId, Target: target}
+	var response findNodeResponse
+	var info, err = n.queryWithInfo(dest, "find_node", arguments, &response)
+	if err != nil {
+		return nil, info, err
+	}
+
+	nodes, err := n.decodeContacts(optionalNodes(response.Nodes))
+	return nodes, info, err
+}
+
+type GetPeersResult struct {
+	// Peers are the peers dest knows for the infohash. If it knows none, Nodes holds closer contacts instead.
+	Peers []net.UDPAddr
+	Nodes []NodeInfo
+	// Token has to be passed to Announce when announcing to the same node.
+	Token string
+}
+
+// GetPeers asks dest for peers of the torrent with the given infohash.
+func (n *Node) GetPeers(dest net.UDPAddr, infoHash NodeId) (GetPeersResult, error) {
+	var arguments = getPeersArguments{Id: n.thisNodeInfo.NodeId, InfoHash: infoHash}
+	var response getPeersResponse
+	if err := n.query(dest, "get_peers", arguments, &response); err != nil {
+		return GetPeersResult{}, err
+	}
+	if len(response.Values) == 0 && response.Nodes == nil {
+		return GetPeersResult{}, fmt.Errorf("invalid get_peers response: contains neither 'values' nor 'nodes'")
+	}
+
+	var result = GetPeersResult{Token: response.Token}
+	for _, value := range response.Values {
+		peer, err := decodeCompactPeerInfo(value)
+		if err != nil {
+			return GetPeersResult{}, err
+		}
+		result.Peers = append(result.Peers, peer)
+	}
+
+	nodes, err := n.decodeContacts(optionalNodes(response.Nodes))
+	if err != nil {
+		return GetPeersResult{}, err
+	}
+	result.Nodes = nodes
+
+	return result, nil
+}
+
+// Announce tells dest that we are a peer of the torrent with the given infohash, using the token from an earlier
+// GetPeers call to the same node. A port of 0 asks dest to use the source port of the query instead.
+func (n *Node) Announce(dest net.UDPAddr, infoHash NodeId, port int, token string) error {
+	var arguments = announcePeerArguments{
+		Id:          n.thisNodeInfo.NodeId,
+		InfoHash:    infoHash,
+		Port:        port,
+		ImpliedPort: port == 0,
+		Token:       token,
+	}
+
+	return n.query(dest, "announce_peer", arguments, &pingResponse{})
+}
+
+type SampleInfohashesResult struct {
+	// Samples are a random selection of the infohashes dest stores peers for.
+	Samples []NodeId
+	// Infohashes is the number of infohashes dest stores in total.
+	Infohashes int
+	// Interval is how long dest asks to wait before querying it for samples again.
+	Interval time.Duration
+	Nodes    []NodeInfo
+}
+
+// SampleInfohashes asks dest for a sample of the infohashes it stores, as in BEP 51. Nodes that do not support it reply
+// with a *krpc.Error with code krpc.ErrorUnknownMethod.
+func (n *Node) SampleInfohashes(dest net.UDPAddr, target NodeId) (SampleInfohashesResult, error) {
+	var arguments = sampleInfohashesArguments{Id: n.thisNodeInfo.NodeId, Target: target}
+	var response sampleInfohashesResponse
+	if err := n.query(dest, "sample_infohashes", arguments, &response); err != nil {
+		return SampleInfohashesResult{}, err
+	}
+
+	if len(response.Samples)%len(NodeId{}) != 0 {
+		return SampleInfohashesResult{}, fmt.Errorf("invalid sample_infohashes response: samples of length %d", len(response.Samples))
+	}
+
+	var result = SampleInfohashesResult{
+		Infohashes: response.Num,
+		Interval:   time.Duration(response.Interval) * time.Second,
+	}
+	for i := 0; i < len(response.Samples); i += len(NodeId{}) {
+		result.Samples = append(result.Samples, NodeId([]byte(response.Samples[i:i+len(NodeId{})])))
+	}
+
+	nodes, err := n.decodeContacts(response.Nodes)
+	if err != nil {
+		return SampleInfohashesResult{}, err
+	}
+	result.Nodes = nodes
+
+	return result, nil
+}
+
+type GetResult struct {
+	// Item is nil if dest does not store an item for the target.
+	Item  *Item
+	Nodes []NodeInfo
+	// Token has to be passed to Put when storing on the same node.
+	Token string
+}
+
+// Get asks dest for the BEP 44 item stored under target. The salt of a mutable item is not part of the response, so
+// it has to be given to verify the item's signature; it is ignored for immutable items. Items that do not match
+// target, or whose signature is invalid, are rejected.
+func (n *Node) Get(dest net.UDPAddr, target NodeId, salt string) (GetResult, error) {
+	var arguments = getArguments{Id: n.thisNodeInfo.NodeId, Target: target}
+	var response getResponse
+	if err := n.query(dest, "get", arguments, &response); err != nil {
+		return GetResult{}, err
+	}
+
+	var result = GetResult{Token: response.Token}
+
+	nodes, err := n.decodeContacts(response.Nodes)
+	if err != nil {
+		return GetResult{}, err
+	}
+	result.Nodes = nodes
+
+	if response.Value != nil {
+		response.Salt = salt
+
+		item, krpcErr := itemFromFields(response.itemFields)
+		if krpcErr != nil {
+			return GetResult{}, errors.New(krpcErr.Message)
+		}
+
+		if item.Target() != target {
+			return GetResult{}, fmt.Errorf("returned item does not match target %s", target)
+		}
+
+		result.Item = &item
+	}
+
+	return result, nil
+}
+
+// Put stores item on dest, using the token from an earlier Get call to the same node.
+func (n *Node) Put(dest net.UDPAddr, item Item, token string) error {
+	var arguments = putArguments{
+		Id:         n.thisNodeInfo.NodeId,
+		Token:      token,
+		itemFields: item.fields(),
+	}
+
+	return n.query(dest, "put", arguments, &pingResponse{})
+}
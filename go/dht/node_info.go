@@ -0,0 +1,228 @@
+package dht
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	mathrand "math/rand/v2"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// NodeId is the 160 bit identifier of a DHT node, also used for infohashes and BEP 44 targets.
+type NodeId [20]byte
+
+func (n NodeId) isBitSet(index int) bool {
+	return (n[index/8] & (1 << uint(7-index%8))) != 0
+}
+
+// withBit returns a copy of n with the bit at index set or cleared.
+func (n NodeId) withBit(index int, set bool) NodeId {
+	var mask = byte(1 << uint(7-index%8))
+	if set {
+		n[index/8] |= mask
+	} else {
+		n[index/8] &^= mask
+	}
+	return n
+}
+
+func (n NodeId) isEqual(other NodeId) bool {
+	return bytes.Equal(n[:], other[:])
+}
+
+func (n NodeId) String() string {
+	return bytesToHexString(n[:])
+}
+
+// Distance is the XOR distance between two ids, the metric of Kademlia. Interpreted as big-endian numbers, smaller
+// distances mean closer ids.
+type Distance [20]byte
+
+func (n NodeId) Distance(other NodeId) Distance {
+	var result Distance
+	for i := range n {
+		result[i] = n[i] ^ other[i]
+	}
+	return result
+}
+
+// Cmp returns -1, 0 or +1 depending on whether d is smaller than, equal to or greater than other.
+func (d Distance) Cmp(other Distance) int {
+	return bytes.Compare(d[:], other[:])
+}
+
+func (d Distance) Less(other Distance) bool {
+	return d.Cmp(other) < 0
+}
+
+func (d Distance) String() string {
+	return bytesToHexString(d[:])
+}
+
+// sortByDistance sorts nodes by their distance to target, closest first. Since XOR is a bijection, distinct ids never
+// have the same distance, so the order is strict.
+func sortByDistance(nodes []NodeInfo, target NodeId) {
+	slices.SortFunc(nodes, func(a, b NodeInfo) int {
+		return a.NodeId.Distance(target).Cmp(b.NodeId.Distance(target))
+	})
+}
+
+// This could benefit from some SIMD instructions
+func commonPrefixLength(a, b NodeId) int {
+	var result int
+	for i := 0; i < 20; i++ {
+		if a[i] != b[i] {
+			result += bits.LeadingZeros8(a[i] ^ b[i])
+			break
+		}
+		result += 8
+	}
+	return result
+}
+
+// NodeInfo is a contact in the DHT: a node id and the address it can be reached at.
+type NodeInfo struct {
+	NodeId  NodeId
+	Address net.UDPAddr
+}
+
+func (n NodeInfo) compactNodeInfo() string {
+	var buffer = make([]byte, 0, 26)
+	buffer = append(buffer, n.NodeId[:]...)
+	buffer = append(buffer, n.Address.IP.To4()...)
+	buffer = binary.BigEndian.AppendUint16(buffer, uint16(n.Address.Port))
+	return string(buffer)
+}
+
+func (n NodeInfo) String() string {
+	return fmt.Sprintf("NodeInfo{%s}", n.NodeId)
+}
+
+func decodeCompactNodeInfo(data string) (NodeInfo, error) {
+	if len(data) != 26 {
+		return NodeInfo{}, fmt.Errorf("decoding compact node info: expected 26 bytes, got %d", len(data))
+	}
+
+	return NodeInfo{
+		NodeId: NodeId([]byte(data[:20])),
+		Address: net.UDPAddr{
+			IP:   net.IPv4(data[20], data[21], data[22], data[23]),
+			Port: int(binary.BigEndian.Uint16([]byte(data[24:]))),
+		},
+	}, nil
+}
+
+func compactPeerInfo(address net.UDPAddr) string {
+	var buffer = make([]byte, 0, 6)
+	buffer = append(buffer, address.IP.To4()...)
+	buffer = binary.BigEndian.AppendUint16(buffer, uint16(address.Port))
+	return string(buffer)
+}
+
+func decodeCompactPeerInfo(data string) (net.UDPAddr, error) {
+	if len(data) != 6 {
+		return net.UDPAddr{}, fmt.Errorf("decoding compact peer info: expected 6 bytes, got %d", len(data))
+	}
+
+	return net.UDPAddr{
+		IP:   net.IPv4(data[0], data[1], data[2], data[3]),
+		Port: int(binary.BigEndian.Uint16([]byte(data[4:]))),
+	}, nil
+}
+
+func encodeCompactNodeInfos(nodes []NodeInfo) string {
+	var builder strings.Builder
+	for _, node := range nodes {
+		builder.WriteString(node.compactNodeInfo())
+	}
+	return builder.String()
+}
+
+func decodeCompactNodeInfos(data string) ([]NodeInfo, error) {
+	if len(data)%26 != 0 {
+		return nil, fmt.Errorf("decoding compact node info list: length %d is not a multiple of 26", len(data))
+	}
+
+	var result = make([]NodeInfo, 0, len(data)/26)
+	for i := 0; i < len(data); i += 26 {
+		var node, err = decodeCompactNodeInfo(data[i : i+26])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, node)
+	}
+
+	return result, nil
+}
+
+// Helpers
+
+func bytesToHexString(b []byte) string {
+	var builder strings.Builder
+	for _, v := range b {
+		builder.WriteString(fmt.Sprintf("%02x", v))
+	}
+	return builder.String()
+}
+
+func hexStringToBytes(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, errors.New("hex string must have an even length")
+	}
+
+	result := make([]byte, len(s)/2)
+	for i := 0; i < len(s)/2; i++ {
+		if b, err := strconv.ParseUint(s[2*i:2*i+2], 16, 8); err != nil {
+			return nil, err
+		} else {
+			result[i] = byte(b)
+		}
+	}
+
+	return result, nil
+}
+
+func hexStringToNodeId(s string) (NodeId, error) {
+	if len(s) != 40 {
+		return NodeId(make([]byte, 20)), errors.New("invalid hex string length")
+	}
+
+	var idBytes, err = hexStringToBytes(s)
+	if err != nil {
+		return NodeId(make([]byte, 20)), err
+	}
+
+	return NodeId(idBytes), nil
+}
+
+// ParseNodeId parses a node id given as 40 hex characters.
+func ParseNodeId(s string) (NodeId, error) {
+	return hexStringToNodeId(s)
+}
+
+// randomIdWithPrefix returns a random id starting with the first length bits of prefix. It is meant for lookup
+// targets, so it does not use crypto/rand.
+func randomIdWithPrefix(prefix NodeId, length int) NodeId {
+	var id NodeId
+	for i := range id {
+		id[i] = byte(mathrand.UintN(256))
+	}
+
+	for i := range length {
+		id = id.withBit(i, prefix.isBitSet(i))
+	}
+	return id
+}
+
+// RandomNodeId returns a node id filled from crypto/rand.
+func RandomNodeId() (NodeId, error) {
+	var id NodeId
+	_, err := rand.Read(id[:])
+	return id, err
+}
@@ -1,8 +1,10 @@
-package main
+package dht
 
 import (
+	"math/big"
 	"net"
 	"testing"
+	"testing/quick"
 )
 
 func TestNodeIdBitSet(t *testing.T) {
@@ -53,9 +55,9 @@ func TestLongestCommonPrefixLength(t *testing.T) {
 
 func TestCompactNodeInfo(t *testing.T) {
 	var id, _ = hexStringToNodeId("000100020003000400050006000700080009000a")
-	var node = nodeInfo{
-		nodeId: id,
-		address: net.UDPAddr{
+	var node = NodeInfo{
+		NodeId: id,
+		Address: net.UDPAddr{
 			IP:   net.ParseIP("12.34.56.78"),
 			Port: 0x9876,
 		},
@@ -67,7 +69,50 @@ func TestCompactNodeInfo(t *testing.T) {
 	}
 
 	var decoded, _ = decodeCompactNodeInfo(string(compactBytes))
-	if !decoded.nodeId.isEqual(id) || decoded.address.IP.String() != "12.34.56.78" || decoded.address.Port != 0x9876 {
+	if !decoded.NodeId.isEqual(id) || decoded.Address.IP.String() != "12.34.56.78" || decoded.Address.Port != 0x9876 {
 		t.Error("Got wrong decoded node info")
 	}
 }
+
+func TestDistanceProperties(t *testing.T) {
+	var properties = map[string]any{
+		"identity": func(a NodeId) bool {
+			return a.Distance(a) == Distance{}
+		},
+		"symmetry": func(a, b NodeId) bool {
+			return a.Distance(b) == b.Distance(a)
+		},
+		"unidirectional": func(a, b, c NodeId) bool {
+			// For a given distance from a, there is exactly one id.
+			return b == c || a.Distance(b) != a.Distance(c)
+		},
+		"composition": func(a, b, c NodeId) bool {
+			var viaB = a.Distance(b)
+			var bc = b.Distance(c)
+			for i := range viaB {
+				viaB[i] ^= bc[i]
+			}
+			return viaB == a.Distance(c)
+		},
+		"triangle inequality": func(a, b, c NodeId) bool {
+			var ab, bc, ac = a.Distance(b), b.Distance(c), a.Distance(c)
+			var sum = new(big.Int).Add(new(big.Int).SetBytes(ab[:]), new(big.Int).SetBytes(bc[:]))
+			return new(big.Int).SetBytes(ac[:]).Cmp(sum) <= 0
+		},
+		"prefix": func(a, b NodeId) bool {
+			var d = a.Distance(b)
+			return a == b || commonPrefixLength(a, b) == commonPrefixLength(NodeId(d), NodeId{})
+		},
+		"order": func(a, b, target NodeId) bool {
+			// The id sharing the longer prefix with target is closer.
+			var pa, pb = commonPrefixLength(a, target), commonPrefixLength(b, target)
+			return pa == pb || (pa > pb) == a.Distance(target).Less(b.Distance(target))
+		},
+	}
+
+	for name, property := range properties {
+		if err := quick.Check(property, nil); err != nil {
+			t.Error("Expected distance property", name, "to hold:", err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package dht
+
+import (
+	"cmp"
+	"crypto/ed25519"
+	"net"
+	"testing"
+	"time"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+func startTestNode(t *testing.T, id string) *Node {
+	var nodeId, _ = hexStringToNodeId(id)
+	return startTestNodeWithConfig(t, Config{NodeId: nodeId})
+}
+
+// startTestNodeWithConfig starts a node on a loopback address, where all test nodes share one IP.
+func startTestNodeWithConfig(t *testing.T, config Config) *Node {
+	config.ListenAddress = "127.0.0.1:0"
+	config.QueryTimeout = cmp.Or(config.QueryTimeout, time.Second)
+	config.AddressPolicy.AllowLoopback = true
+	config.SybilLimits.Unlimited = true
+
+	var node, err = NewNode(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { node.Close() })
+
+	return node
+}
+
+func TestNodePingAndFindNode(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b = startTestNode(t, "8000000000000000000000000000000000000001")
+
+	var id, err = a.Ping(b.Address())
+	if err != nil || !id.isEqual(b.Id()) {
+		t.Fatal("Expected ping to return id of b, got", id, "err:", err)
+	}
+
+	// b does not know anyone yet, so it can only return itself when asked for its own id
+	nodes, err := a.FindNode(b.Address(), b.Id())
+	if err != nil || len(nodes) != 1 || !nodes[0].NodeId.isEqual(b.Id()) || nodes[0].Address.Port != b.Address().Port {
+		t.Error("Expected find_node to return b, got", nodes, "err:", err)
+	}
+}
+
+func TestNodeGetPeersAndAnnounce(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b = startTestNode(t, "8000000000000000000000000000000000000001")
+	var infoHash, _ = hexStringToNodeId("1234567890123456789012345678901234567890")
+
+	var result, err = a.GetPeers(b.Address(), infoHash)
+	if err != nil || len(result.Peers) != 0 || result.Token == "" {
+		t.Fatal("Expected no peers and a token, got", result, "err:", err)
+	}
+
+	if err := a.Announce(b.Address(), infoHash, 4321, "wrong token"); err == nil {
+		t.Error("Expected announce with an invalid token to fail")
+	}
+
+	if err := a.Announce(b.Address(), infoHash, 4321, result.Token); err != nil {
+		t.Fatal("Expected announce to succeed, got", err)
+	}
+
+	result, err = a.GetPeers(b.Address(), infoHash)
+	if err != nil || len(result.Peers) != 1 || result.Peers[0].Port != 4321 {
+		t.Error("Expected announced peer, got", result, "err:", err)
+	}
+}
+
+func TestNodeGetAndPut(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b = startTestNode(t, "8000000000000000000000000000000000000001")
+
+	var immutable = NewImmutableItem(bencode.String("Hello World!"))
+	if immutable.Target().String() != "e5f96f6f38320f0f33959cb4d3d656452117aadb" {
+		t.Error("Expected BEP 44 test vector target, got", immutable.Target())
+	}
+
+	var result, err = a.Get(b.Address(), immutable.Target(), "")
+	if err != nil || result.Item != nil {
+		t.Fatal("Expected no item, got", result, "err:", err)
+	}
+
+	if err := a.Put(b.Address(), immutable, result.Token); err != nil {
+		t.Fatal("Expected put to succeed, got", err)
+	}
+
+	result, err = a.Get(b.Address(), immutable.Target(), "")
+	if err != nil || result.Item == nil || result.Item.Value.(bencode.String) != "Hello World!" {
+		t.Error("Expected stored item, got", result, "err:", err)
+	}
+
+	var _, privateKey, _ = ed25519.GenerateKey(nil)
+	var mutable = NewMutableItem(bencode.String("v1"), 2, "salt", privateKey)
+	if err := a.Put(b.Address(), mutable, result.Token); err != nil {
+		t.Fatal("Expected mutable put to succeed, got", err)
+	}
+
+	var outdated = NewMutableItem(bencode.String("v0"), 1, "salt", privateKey)
+	if err := a.Put(b.Address(), outdated, result.Token); err == nil {
+		t.Error("Expected put with a lower sequence number to fail")
+	}
+
+	var forged = mutable
+	forged.Value = bencode.String("forged")
+	if err := a.Put(b.Address(), forged, result.Token); err == nil {
+		t.Error("Expected put with an invalid signature to fail")
+	}
+
+	result, err = a.Get(b.Address(), mutable.Target(), "salt")
+	if err != nil || result.Item == nil || result.Item.Seq != 2 || result.Item.Value.(bencode.String) != "v1" {
+		t.Error("Expected stored mutable item, got", result, "err:", err)
+	}
+}
+
+func TestNodeRejectsInvalidArguments(t *testing.T) {
+	var node = startTestNode(t, "0000000000000000000000000000000000000001")
+	var source = node.Address()
+
+	var response = node.handleQuery(&krpc.Query{MethodName: "find_node", Arguments: bencode.Dict{
+		"id": bencode.String("01234567890123456789"),
+	}}, &source)
+	if err, ok := response.(*krpc.Error); !ok || err.Message != "Missing 'target' argument" {
+		t.Error("Expected missing target to be reported, got", response)
+	}
+
+	response = node.handleQuery(&krpc.Query{MethodName: "find_node", Arguments: bencode.Dict{
+		"id":     bencode.String("01234567890123456789"),
+		"target": bencode.String("too short"),
+	}}, &source)
+	if err, ok := response.(*krpc.Error); !ok || err.Message != "Invalid 'target' argument" {
+		t.Error("Expected invalid target to be reported, got", response)
+	}
+}
+
+// lockedEntries returns the entries of all buckets, for tables that are in use by a running node.
+func lockedEntries(table *routingTable) []routingEntry {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+
+	var result []routingEntry
+	for _, bucket := range table.table {
+		result = append(result, bucket.entries...)
+	}
+	return result
+}
+
+func TestNodeLearnsContactsFromQueries(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b = startTestNode(t, "8000000000000000000000000000000000000001")
+
+	if _, err := a.FindNode(b.Address(), a.Id()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a learned b from its response, b learned a from its query.
+	var aEntries = lockedEntries(a.routingTable)
+	if len(aEntries) != 1 || aEntries[0].NodeId != b.Id() || aEntries[0].lastResponded.IsZero() {
+		t.Error("Expected a to know b as responded, got", aEntries)
+	}
+
+	var bEntries = lockedEntries(b.routingTable)
+	if len(bEntries) != 1 || bEntries[0].NodeId != a.Id() || bEntries[0].lastSeen.IsZero() || !bEntries[0].lastResponded.IsZero() {
+		t.Error("Expected b to know a as seen, got", bEntries)
+	}
+}
+
+// replyOnce answers the next query arriving at conn with a response holding returnValues.
+func replyOnce(t *testing.T, conn *net.UDPConn, returnValues bencode.Dict) {
+	var buffer = make([]byte, 1500)
+	var n, source, err = conn.ReadFromUDP(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	value, err := bencode.Decode(string(buffer[:n]))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	query, err := krpc.DecodeMessage(value.(bencode.Dict))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var response = krpc.Response{TransactionId: query.GetTransactionId(), ReturnValues: returnValues}
+	conn.WriteToUDP([]byte(response.Encode()), source)
+}
+
+func TestNodeHandlesResponsesWithoutNodes(t *testing.T) {
+	var node = startTestNode(t, "0000000000000000000000000000000000000001")
+	var conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var remote = *conn.LocalAddr().(*net.UDPAddr)
+	var id = bencode.String("88888888888888888888")
+
+	// A node with an empty routing table has no contacts to return.
+	go replyOnce(t, conn, bencode.Dict{"id": id})
+	if nodes, err := node.FindNode(remote, NodeId{}); err != nil || len(nodes) != 0 {
+		t.Error("Expected an empty find_node response to be accepted, got", nodes, "err:", err)
+	}
+
+	// A get_peers response has to contain either peers or contacts.
+	go replyOnce(t, conn, bencode.Dict{"id": id, "token": bencode.String("token")})
+	if result, err := node.GetPeers(remote, NodeId{}); err == nil {
+		t.Error("Expected a get_peers response without values or nodes to be rejected, got", result)
+	}
+}
+
+func TestNodeReplacesSilentEvictionCandidate(t *testing.T) {
+	var a = startTestNodeWithConfig(t, Config{NodeId: NodeId{0x00}, BucketSize: 2, QueryTimeout: 200 * time.Millisecond})
+	var b = startTestNode(t, "8200000000000000000000000000000000000001")
+
+	// Fill the far bucket of a with two nodes that stopped answering a while ago.
+	for i, id := range []NodeId{{0x80}, {0x81}} {
+		var silent, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer silent.Close()
+		a.routingTable.addEntry(NodeInfo{NodeId: id, Address: *silent.LocalAddr().(*net.UDPAddr)}, contactResponded)
+
+		a.routingTable.lock.Lock()
+		a.routingTable.table[0].entries[i].lastResponded = time.Now().Add(-questionableAfter - time.Duration(2-i)*time.Minute)
+		a.routingTable.lock.Unlock()
+	}
+
+	// b responding to a makes a ping the least recently seen node, which times out, so b takes its place.
+	if _, err := a.Ping(b.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	var deadline = time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		var ids []NodeId
+		for _, entry := range lockedEntries(a.routingTable) {
+			ids = append(ids, entry.NodeId)
+		}
+		if len(ids) == 2 && ids[0] == (NodeId{0x81}) && ids[1] == b.Id() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected b to replace the silent head of the bucket, got", lockedEntries(a.routingTable))
+}
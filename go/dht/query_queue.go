@@ -0,0 +1,131 @@
+package dht
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"dhtcli/krpc"
+)
+
+const DefaultQueryWorkers = 16
+
+const DefaultQueryQueueSize = 256
+
+// QueryQueue configures how incoming queries are handled: a fixed number of workers take them from a queue of bounded
+// size, so that a burst of queries cannot start an unbounded number of goroutines. Zero values are replaced with
+// DefaultQueryWorkers and DefaultQueryQueueSize.
+type QueryQueue struct {
+	Workers int
+	Size    int
+	// DropOldest drops the query that has been waiting longest when the queue is full, instead of the new one. Its
+	// sender may well have given up on it already.
+	DropOldest bool
+}
+
+// QueryQueueStats describes the load on the query queue. Latency is measured from receiving a query to sending the
+// response, so it includes the time spent waiting in the queue.
+type QueryQueueStats struct {
+	Handled        uint64
+	Dropped        uint64
+	QueueDepth     int
+	MaxQueueDepth  int
+	AverageLatency time.Duration
+	MaxLatency     time.Duration
+}
+
+type queuedQuery struct {
+	query    *krpc.Query
+	source   *net.UDPAddr
+	received time.Time
+}
+
+type queryQueue struct {
+	config  QueryQueue
+	queries chan queuedQuery
+
+	handled      atomic.Uint64
+	dropped      atomic.Uint64
+	maxDepth     atomic.Int64
+	totalLatency atomic.Int64
+	maxLatency   atomic.Int64
+}
+
+func newQueryQueue(config QueryQueue) *queryQueue {
+	if config.Workers <= 0 {
+		config.Workers = DefaultQueryWorkers
+	}
+	if config.Size <= 0 {
+		config.Size = DefaultQueryQueueSize
+	}
+
+	return &queryQueue{
+		config:  config,
+		queries: make(chan queuedQuery, config.Size),
+	}
+}
+
+// push queues a query, dropping a query if the queue is full.
+func (q *queryQueue) push(query queuedQuery) {
+	for {
+		select {
+		case q.queries <- query:
+			storeMax(&q.maxDepth, int64(len(q.queries)))
+			return
+		default:
+		}
+
+		if !q.config.DropOldest {
+			q.dropped.Add(1)
+			return
+		}
+
+		// A worker may take the oldest query in the meantime, in which case there is room again and nothing is dropped.
+		select {
+		case <-q.queries:
+			q.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// work handles queries until done is closed.
+func (q *queryQueue) work(handle func(queuedQuery), done <-chan struct{}) {
+	for {
+		select {
+		case query := <-q.queries:
+			handle(query)
+
+			var latency = time.Since(query.received)
+			q.handled.Add(1)
+			q.totalLatency.Add(int64(latency))
+			storeMax(&q.maxLatency, int64(latency))
+		case <-done:
+			return
+		}
+	}
+}
+
+func storeMax(value *atomic.Int64, candidate int64) {
+	for {
+		var current = value.Load()
+		if candidate <= current || value.CompareAndSwap(current, candidate) {
+			return
+		}
+	}
+}
+
+func (q *queryQueue) stats() QueryQueueStats {
+	var stats = QueryQueueStats{
+		Handled:       q.handled.Load(),
+		Dropped:       q.dropped.Load(),
+		QueueDepth:    len(q.queries),
+		MaxQueueDepth: int(q.maxDepth.Load()),
+		MaxLatency:    time.Duration(q.maxLatency.Load()),
+	}
+
+	if stats.Handled > 0 {
+		stats.AverageLatency = time.Duration(q.totalLatency.Load() / int64(stats.Handled))
+	}
+	return stats
+}
@@ -0,0 +1,74 @@
+package dht
+
+import (
+	"testing"
+	"time"
+
+	"dhtcli/krpc"
+)
+
+func queueTransactionIds(q *queryQueue) []string {
+	var ids []string
+	for len(q.queries) > 0 {
+		ids = append(ids, (<-q.queries).query.TransactionId)
+	}
+	return ids
+}
+
+func TestQueryQueueDropsWhenFull(t *testing.T) {
+	for _, dropOldest := range []bool{false, true} {
+		var queue = newQueryQueue(QueryQueue{Size: 2, DropOldest: dropOldest})
+		for _, id := range []string{"a", "b", "c"} {
+			queue.push(queuedQuery{query: &krpc.Query{TransactionId: id}})
+		}
+
+		var stats = queue.stats()
+		if stats.Dropped != 1 || stats.QueueDepth != 2 || stats.MaxQueueDepth != 2 {
+			t.Error("Expected 1 dropped query and 2 queued, got", stats)
+		}
+
+		var expected = "ab"
+		if dropOldest {
+			expected = "bc"
+		}
+		if ids := queueTransactionIds(queue); len(ids) != 2 || ids[0]+ids[1] != expected {
+			t.Error("Expected queue to hold", expected, "with DropOldest", dropOldest, "got", ids)
+		}
+	}
+}
+
+func TestQueryQueueWorkers(t *testing.T) {
+	var queue = newQueryQueue(QueryQueue{Workers: 2})
+	var done = make(chan struct{})
+	defer close(done)
+
+	var handled = make(chan string)
+	for range queue.config.Workers {
+		go queue.work(func(query queuedQuery) {
+			time.Sleep(10 * time.Millisecond)
+			handled <- query.query.TransactionId
+		}, done)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		queue.push(queuedQuery{query: &krpc.Query{TransactionId: id}, received: time.Now()})
+	}
+	for range 3 {
+		<-handled
+	}
+
+	// Queries are counted just after they were handled.
+	var stats = queue.stats()
+	for deadline := time.Now().Add(time.Second); stats.Handled < 3 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		stats = queue.stats()
+	}
+
+	if stats.Handled != 3 || stats.Dropped != 0 || stats.QueueDepth != 0 {
+		t.Error("Expected 3 handled queries, got", stats)
+	}
+
+	if stats.AverageLatency < 10*time.Millisecond || stats.MaxLatency < stats.AverageLatency {
+		t.Error("Expected latencies of at least 10ms, got", stats.AverageLatency, stats.MaxLatency)
+	}
+}
@@ -0,0 +1,187 @@
+package dht
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit limits the incoming queries a node handles, with a token bucket per source IP, per source prefix (/24 for
+// IPv4, /64 for IPv6, as one host usually controls at least that much) and for all sources together. Rates are in
+// queries per second, zero means unlimited. Responses to our own queries are never limited.
+type RateLimit struct {
+	Global    float64
+	PerIP     float64
+	PerPrefix float64
+	// Burst is the number of seconds a bucket can save up, i.e. a bucket holds up to Burst times its rate in queries.
+	// It defaults to 1.
+	Burst float64
+	// Reply answers queries over the limit with a 202 error instead of dropping them silently.
+	Reply bool
+}
+
+// RateLimitStats counts the incoming queries that were handled or rejected, by the limit that rejected them.
+type RateLimitStats struct {
+	Allowed        uint64
+	LimitedIP      uint64
+	LimitedPrefix  uint64
+	LimitedGlobal  uint64
+	RepliedLimited uint64
+}
+
+const rateLimitSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time, rate float64, capacity float64) {
+	if b.updated.IsZero() {
+		b.tokens = capacity
+	} else {
+		b.tokens = min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	}
+	b.updated = now
+}
+
+type rateLimiter struct {
+	config    RateLimit
+	global    tokenBucket
+	ips       map[netip.Addr]*tokenBucket
+	prefixes  map[netip.Prefix]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+	lock      sync.Mutex
+
+	allowed        atomic.Uint64
+	limitedIP      atomic.Uint64
+	limitedPrefix  atomic.Uint64
+	limitedGlobal  atomic.Uint64
+	repliedLimited atomic.Uint64
+}
+
+func newRateLimiter(config RateLimit) *rateLimiter {
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+
+	return &rateLimiter{
+		config:   config,
+		ips:      make(map[netip.Addr]*tokenBucket),
+		prefixes: make(map[netip.Prefix]*tokenBucket),
+		now:      time.Now,
+	}
+}
+
+func sourcePrefix(ip netip.Addr) netip.Prefix {
+	var bits = 64
+	if ip.Is4() {
+		bits = 24
+	}
+
+	var prefix, _ = ip.Prefix(bits)
+	return prefix
+}
+
+// capacity is the size of a bucket for the given rate, which always fits at least one query.
+func (l *rateLimiter) capacity(rate float64) float64 {
+	return max(1, rate*l.config.Burst)
+}
+
+// allow reports whether a query from source may be handled, taking a token from each of its buckets if so.
+func (l *rateLimiter) allow(source net.IP) bool {
+	if l.config.Global <= 0 && l.config.PerIP <= 0 && l.config.PerPrefix <= 0 {
+		l.allowed.Add(1)
+		return true
+	}
+
+	var ip, _ = netip.AddrFromSlice(source)
+	ip = ip.Unmap()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var now = l.now()
+	l.sweep(now)
+
+	// Only take tokens once all buckets have one, so that rejected queries do not count against the other limits.
+	var buckets []*tokenBucket
+	if l.config.PerIP > 0 {
+		var bucket = bucketFor(l.ips, ip)
+		bucket.refill(now, l.config.PerIP, l.capacity(l.config.PerIP))
+		if bucket.tokens < 1 {
+			l.limitedIP.Add(1)
+			return false
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if l.config.PerPrefix > 0 {
+		var bucket = bucketFor(l.prefixes, sourcePrefix(ip))
+		bucket.refill(now, l.config.PerPrefix, l.capacity(l.config.PerPrefix))
+		if bucket.tokens < 1 {
+			l.limitedPrefix.Add(1)
+			return false
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if l.config.Global > 0 {
+		l.global.refill(now, l.config.Global, l.capacity(l.config.Global))
+		if l.global.tokens < 1 {
+			l.limitedGlobal.Add(1)
+			return false
+		}
+		buckets = append(buckets, &l.global)
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+
+	l.allowed.Add(1)
+	return true
+}
+
+func bucketFor[K comparable](buckets map[K]*tokenBucket, key K) *tokenBucket {
+	var bucket = buckets[key]
+	if bucket == nil {
+		bucket = &tokenBucket{}
+		buckets[key] = bucket
+	}
+	return bucket
+}
+
+// sweep forgets the sources whose buckets have filled up again, since a new bucket starts out full anyway. This keeps
+// the maps from growing with every address that ever sent us a query.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for ip, bucket := range l.ips {
+		if bucket.refill(now, l.config.PerIP, l.capacity(l.config.PerIP)); bucket.tokens >= l.capacity(l.config.PerIP) {
+			delete(l.ips, ip)
+		}
+	}
+
+	for prefix, bucket := range l.prefixes {
+		if bucket.refill(now, l.config.PerPrefix, l.capacity(l.config.PerPrefix)); bucket.tokens >= l.capacity(l.config.PerPrefix) {
+			delete(l.prefixes, prefix)
+		}
+	}
+}
+
+func (l *rateLimiter) stats() RateLimitStats {
+	return RateLimitStats{
+		Allowed:        l.allowed.Load(),
+		LimitedIP:      l.limitedIP.Load(),
+		LimitedPrefix:  l.limitedPrefix.Load(),
+		LimitedGlobal:  l.limitedGlobal.Load(),
+		RepliedLimited: l.repliedLimited.Load(),
+	}
+}
@@ -0,0 +1,104 @@
+package dht
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"dhtcli/krpc"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var now = time.Unix(1700000000, 0)
+	var limiter = newRateLimiter(RateLimit{PerIP: 2, PerPrefix: 3, Global: 10})
+	limiter.now = func() time.Time { return now }
+
+	var a = net.ParseIP("10.0.0.1")
+	var b = net.ParseIP("10.0.0.2")
+	var c = net.ParseIP("10.0.1.1")
+
+	// a can send 2 queries, after which b from the same /24 can only send 1 more within the prefix limit.
+	for i, expected := range []bool{true, true, false} {
+		if limiter.allow(a) != expected {
+			t.Error("Expected query", i, "from a to be allowed:", expected)
+		}
+	}
+	for i, expected := range []bool{true, false} {
+		if limiter.allow(b) != expected {
+			t.Error("Expected query", i, "from b to be allowed:", expected)
+		}
+	}
+	if !limiter.allow(c) {
+		t.Error("Expected query from another prefix to be allowed")
+	}
+
+	var stats = limiter.stats()
+	if stats.Allowed != 4 || stats.LimitedIP != 1 || stats.LimitedPrefix != 1 {
+		t.Error("Expected 4 allowed, 1 limited by ip and 1 by prefix, got", stats)
+	}
+
+	now = now.Add(time.Second)
+	if !limiter.allow(a) || !limiter.allow(a) {
+		t.Error("Expected the bucket of a to be refilled after a second")
+	}
+
+	// IPv4-mapped IPv6 addresses count as the IPv4 address.
+	if limiter.allow(a.To16()) {
+		t.Error("Expected mapped address to share the bucket of a")
+	}
+
+	now = now.Add(time.Hour)
+	limiter.allow(c)
+	if len(limiter.ips) != 1 || len(limiter.prefixes) != 1 {
+		t.Error("Expected idle buckets to be swept, got", len(limiter.ips), "ips and", len(limiter.prefixes), "prefixes")
+	}
+}
+
+func TestRateLimiterGlobal(t *testing.T) {
+	var limiter = newRateLimiter(RateLimit{Global: 3})
+	limiter.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	for i := range 5 {
+		var ip = net.IPv6loopback
+		if i%2 == 0 {
+			ip = net.ParseIP("2001:db8::1")
+		}
+		if limiter.allow(ip) != (i < 3) {
+			t.Error("Expected only the first 3 queries to be allowed, got query", i, "wrong")
+		}
+	}
+
+	if stats := limiter.stats(); stats.LimitedGlobal != 2 {
+		t.Error("Expected 2 globally limited queries, got", stats)
+	}
+}
+
+func TestNodeRateLimitReply(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b, err = NewNode(Config{
+		ListenAddress: "127.0.0.1:0",
+		QueryTimeout:  time.Second,
+		RateLimit:     RateLimit{PerIP: 1, Reply: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if _, err := a.Ping(b.Address()); err != nil {
+		t.Fatal("Expected first ping to succeed, got", err)
+	}
+
+	var krpcError *krpc.Error
+	if _, err := a.Ping(b.Address()); !errors.As(err, &krpcError) || krpcError.Code != krpc.ErrorServer {
+		t.Error("Expected second ping to fail with a server error, got", err)
+	}
+
+	if stats := b.RateLimitStats(); stats.Allowed != 1 || stats.LimitedIP != 1 || stats.RepliedLimited != 1 {
+		t.Error("Expected 1 allowed and 1 limited query, got", stats)
+	}
+}
@@ -0,0 +1,204 @@
+package dht
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A node that has neither responded to us nor queried us for this long is questionable, as per BEP 5.
+const questionableAfter = 15 * time.Minute
+
+// A node that failed to respond to this many queries in a row is bad.
+const badAfterFailures = 3
+
+type nodeStatus int
+
+const (
+	nodeGood nodeStatus = iota
+	nodeQuestionable
+	nodeBad
+)
+
+func (s nodeStatus) String() string {
+	switch s {
+	case nodeGood:
+		return "good"
+	case nodeQuestionable:
+		return "questionable"
+	default:
+		return "bad"
+	}
+}
+
+// routingEntry is a contact in the routing table, along with what we know about its quality.
+type routingEntry struct {
+	NodeInfo
+	// lastSeen is when the node last sent us a query, lastResponded when it last answered one of ours.
+	lastSeen      time.Time
+	lastResponded time.Time
+	// added is when the node entered the routing table.
+	added         time.Time
+	failedQueries int
+}
+
+// status rates the node as in BEP 5: it is good if it responded to us recently, or if it ever responded and queried us
+// recently.
+func (e routingEntry) status(now time.Time) nodeStatus {
+	switch {
+	case e.failedQueries >= badAfterFailures:
+		return nodeBad
+	case now.Sub(e.lastResponded) < questionableAfter:
+		return nodeGood
+	case !e.lastResponded.IsZero() && now.Sub(e.lastSeen) < questionableAfter:
+		return nodeGood
+	default:
+		return nodeQuestionable
+	}
+}
+
+type bucket struct {
+	bucketSize int
+	entries    []routingEntry
+	// The bucket covers the ids that start with the first prefixLength bits of prefix. The remaining bits of prefix are
+	// zero.
+	prefix       NodeId
+	prefixLength int
+}
+
+// newBucket returns an empty bucket covering the whole id space.
+func newBucket(bucketSize int) bucket {
+	return bucket{
+		bucketSize: bucketSize,
+		entries:    make([]routingEntry, 0, bucketSize),
+	}
+}
+
+// contains reports whether id falls into the range of the bucket.
+func (b bucket) contains(id NodeId) bool {
+	return commonPrefixLength(b.prefix, id) >= b.prefixLength
+}
+
+// idRange returns the smallest and largest id in the range of the bucket.
+func (b bucket) idRange() (first NodeId, last NodeId) {
+	first, last = b.prefix, b.prefix
+	for i := b.prefixLength; i < 160; i++ {
+		last = last.withBit(i, true)
+	}
+	return first, last
+}
+
+// randomId returns a random id in the range of the bucket, e.g. as the target of a lookup that refreshes it.
+func (b bucket) randomId() NodeId {
+	return randomIdWithPrefix(b.prefix, b.prefixLength)
+}
+
+func (b bucket) addEntry(entry NodeInfo) (updated bucket, success bool) {
+	return b.add(routingEntry{NodeInfo: entry})
+}
+
+// add appends entry at the tail of the bucket, so that entries are ordered from least to most recently seen. If the
+// node is already in the bucket, it takes over the activity of entry and moves to the tail instead.
+func (b bucket) add(entry routingEntry) (updated bucket, success bool) {
+	if i := b.indexOf(entry.NodeId); i >= 0 {
+		var existing = b.entries[i]
+		if !entry.lastResponded.IsZero() {
+			existing.lastResponded = entry.lastResponded
+			existing.failedQueries = 0
+		}
+		if !entry.lastSeen.IsZero() {
+			existing.lastSeen = entry.lastSeen
+		}
+		b.moveToTail(i, existing)
+		return b, true
+	}
+
+	if len(b.entries) >= b.bucketSize {
+		return b, false
+	}
+
+	b.entries = append(b.entries, entry)
+	return b, true
+}
+
+// moveToTail replaces the entry at index with entry, and moves it behind all others.
+func (b bucket) moveToTail(index int, entry routingEntry) {
+	copy(b.entries[index:], b.entries[index+1:])
+	b.entries[len(b.entries)-1] = entry
+}
+
+// evictionCandidate returns the least recently seen entry, which is the first to be checked when the bucket is full.
+func (b bucket) evictionCandidate() (routingEntry, bool) {
+	if len(b.entries) == 0 {
+		return routingEntry{}, false
+	}
+	return b.entries[0], true
+}
+
+func (b bucket) indexOf(id NodeId) int {
+	for i, entry := range b.entries {
+		if entry.NodeId.isEqual(id) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b bucket) nodeInfos() []NodeInfo {
+	var result = make([]NodeInfo, len(b.entries))
+	for i, entry := range b.entries {
+		result[i] = entry.NodeInfo
+	}
+	return result
+}
+
+func (b bucket) containsNodeId(id NodeId) bool {
+	return b.indexOf(id) >= 0
+}
+
+func (b bucket) getEntryByIdOrReturnAll(id NodeId) (result []NodeInfo, exactMatch bool) {
+	for _, entry := range b.entries {
+		if entry.NodeId.isEqual(id) {
+			return []NodeInfo{entry.NodeInfo}, true
+		}
+	}
+
+	return b.nodeInfos(), false
+}
+
+func (b bucket) splitAt(bitPosition int) (zeroBucket bucket, oneBucket bucket) {
+	if bitPosition < 0 || bitPosition > 159 {
+		panic("Invalid bit position")
+	}
+
+	zeroBucket = newBucket(b.bucketSize)
+	zeroBucket.prefix = b.prefix.withBit(bitPosition, false)
+	zeroBucket.prefixLength = bitPosition + 1
+	oneBucket = newBucket(b.bucketSize)
+	oneBucket.prefix = b.prefix.withBit(bitPosition, true)
+	oneBucket.prefixLength = bitPosition + 1
+
+	for _, entry := range b.entries {
+		if entry.NodeId.isBitSet(bitPosition) {
+			oneBucket, _ = oneBucket.add(entry)
+		} else {
+			zeroBucket, _ = zeroBucket.add(entry)
+		}
+	}
+
+	return zeroBucket, oneBucket
+}
+
+func (b bucket) String() string {
+	var builder strings.Builder
+
+	for i := range b.bucketSize {
+		if i >= len(b.entries) {
+			builder.WriteString("---------------------------------------- ")
+		} else {
+			builder.WriteString(fmt.Sprintf("%s ", b.entries[i].NodeId))
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,159 @@
+package dht
+
+import (
+	"testing"
+)
+
+func TestBucketAddEntry(t *testing.T) {
+	var bucket = newBucket(2)
+
+	var nodeId1, _ = hexStringToNodeId("9000000800900000080000000000000000000001")
+	var nodeId2, _ = hexStringToNodeId("9000000800900000080000000000000000000002")
+	var nodeId3, _ = hexStringToNodeId("9000000800900000080000000000000000000003")
+
+	bucket, success := bucket.addEntry(NodeInfo{NodeId: nodeId1})
+	if !success {
+		t.Error("Expected addEntry to return true")
+	}
+
+	bucket, success = bucket.addEntry(NodeInfo{NodeId: nodeId2})
+	if !success {
+		t.Error("Expected addEntry to return true")
+	}
+
+	bucket, success = bucket.addEntry(NodeInfo{NodeId: nodeId3})
+	if success {
+		t.Error("Expected addEntry to return false")
+	}
+	if !bucket.containsNodeId(nodeId1) {
+		t.Error("Expected bucket to contain nodeId1")
+	}
+	if !bucket.containsNodeId(nodeId2) {
+		t.Error("Expected bucket to contain nodeId2")
+	}
+	if bucket.containsNodeId(nodeId3) {
+		t.Error("Expected bucket to not contain nodeId3")
+	}
+}
+
+func TestBucketGetEntryByIdOrReturnAll(t *testing.T) {
+	var bucket = newBucket(8)
+
+	var nodeId1, _ = hexStringToNodeId("9000000800900000080000000000000000000001")
+	var nodeId2, _ = hexStringToNodeId("9000000800900000080000000000000000000002")
+	var nodeId3, _ = hexStringToNodeId("9000000800900000080000000000000000000003")
+
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: nodeId1})
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: nodeId2})
+
+	var result, exactMatch = bucket.getEntryByIdOrReturnAll(nodeId1)
+	if !exactMatch || len(result) != 1 || !result[0].NodeId.isEqual(nodeId1) {
+		t.Error("Expected exact match")
+	}
+
+	result, exactMatch = bucket.getEntryByIdOrReturnAll(nodeId2)
+	if !exactMatch || len(result) != 1 || !result[0].NodeId.isEqual(nodeId2) {
+		t.Error("Expected exact match")
+	}
+
+	result, exactMatch = bucket.getEntryByIdOrReturnAll(nodeId3)
+	if exactMatch || len(result) != 2 {
+		t.Error("Expected all entries")
+	}
+}
+
+func TestSplitAt(t *testing.T) {
+	var bucket = newBucket(8)
+	var nodeId1, _ = hexStringToNodeId("0000000000000000000000000000000000000001")
+	var nodeId2, _ = hexStringToNodeId("f000000000000000000000000000000000000002")
+	var nodeId3, _ = hexStringToNodeId("0000000000000000000000000000000000000003")
+	var nodeId4, _ = hexStringToNodeId("0000000000000000000000000000000000000004")
+	var nodeId5, _ = hexStringToNodeId("f000000000000000000000000000000000000005")
+
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: nodeId1})
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: nodeId2})
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: nodeId3})
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: nodeId4})
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: nodeId5})
+
+	var zero, one = bucket.splitAt(0)
+	if len(zero.entries) != 3 {
+		t.Error("Expected zero to have 3 entries")
+	}
+	if len(one.entries) != 2 {
+		t.Error("Expected one to have 2 entries")
+	}
+	if !zero.containsNodeId(nodeId1) {
+		t.Error("Expected zero to contain nodeId1")
+	}
+	if !one.containsNodeId(nodeId2) {
+		t.Error("Expected one to contain nodeId2")
+	}
+}
+
+func TestBucketRange(t *testing.T) {
+	var zero, one = newBucket(8).splitAt(0)
+	var zeroZero, _ = zero.splitAt(1)
+
+	var first, last = one.idRange()
+	if first != (NodeId{0x80}) || last.String() != "ffffffffffffffffffffffffffffffffffffffff" {
+		t.Error("Expected bucket 1* to range from 80... to ff..., got", first, last)
+	}
+
+	first, last = zeroZero.idRange()
+	if first != (NodeId{0x00}) || last.String() != "3fffffffffffffffffffffffffffffffffffffff" {
+		t.Error("Expected bucket 00* to range from 00... to 3f..., got", first, last)
+	}
+
+	if !zeroZero.contains(NodeId{0x3f, 0xff}) || zeroZero.contains(NodeId{0x40}) || !newBucket(8).contains(NodeId{0xff}) {
+		t.Error("Expected contains to check the prefix of the bucket")
+	}
+
+	for range 100 {
+		if id := zeroZero.randomId(); !zeroZero.contains(id) {
+			t.Error("Expected random id to fall into bucket 00*, got", id)
+		}
+		if id := one.randomId(); !one.contains(id) {
+			t.Error("Expected random id to fall into bucket 1*, got", id)
+		}
+	}
+
+	// After splitting, every bucket of a table holds exactly the entries in its range.
+	var table = newRoutingTable(2, NodeInfo{NodeId: NodeId{0x5a}})
+	for i := range 64 {
+		table.addEntry(NodeInfo{NodeId: NodeId{byte(i * 4), byte(i)}}, contactResponded)
+	}
+	for i, bucket := range table.table {
+		for _, entry := range bucket.entries {
+			if !bucket.contains(entry.NodeId) {
+				t.Error("Expected bucket", i, "to contain", entry.NodeId)
+			}
+		}
+		if i < len(table.table)-1 && bucket.contains(table.thisNodeInfo.NodeId) {
+			t.Error("Expected only the last bucket to contain our own id, got bucket", i)
+		}
+	}
+}
+
+func TestBucketLeastRecentlySeenOrder(t *testing.T) {
+	var bucket = newBucket(3)
+	for _, id := range []NodeId{{1}, {2}, {3}} {
+		bucket, _ = bucket.addEntry(NodeInfo{NodeId: id})
+	}
+
+	bucket, _ = bucket.addEntry(NodeInfo{NodeId: NodeId{1}})
+	var ids []byte
+	for _, entry := range bucket.entries {
+		ids = append(ids, entry.NodeId[0])
+	}
+	if string(ids) != "\x02\x03\x01" {
+		t.Error("Expected the node seen again to move to the tail, got", ids)
+	}
+
+	if head, ok := bucket.evictionCandidate(); !ok || head.NodeId != (NodeId{2}) {
+		t.Error("Expected the least recently seen node to be the eviction candidate, got", head.NodeId)
+	}
+	if _, ok := newBucket(3).evictionCandidate(); ok {
+		t.Error("Expected an empty bucket to have no eviction candidate")
+	}
+}
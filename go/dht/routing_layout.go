@@ -0,0 +1,73 @@
+package dht
+
+// RoutingTableLayout selects how the routing table is structured. The zero value is the layout of BEP 5: all buckets
+// hold BucketSize nodes, and only the bucket containing our own id is split.
+type RoutingTableLayout struct {
+	// ExtendedBuckets makes the buckets closest to our own id larger, see extendedBucketFactors. These are the buckets
+	// lookups for ids near ours depend on, and the ones most likely to run short of good nodes.
+	ExtendedBuckets bool
+	// RelaxedSplit also splits full buckets that do not contain our own id, as long as the new node would be among the
+	// BucketSize nodes closest to our own id. This keeps more of the nodes in our neighbourhood.
+	RelaxedSplit bool
+}
+
+// extendedBucketFactors multiply the bucket size for extended buckets, starting with the bucket containing our own id
+// and moving one level away from it with each factor.
+var extendedBucketFactors = []int{4, 2}
+
+// bucketDepth returns how many leading bits the ids in b share with our own id.
+func (t *routingTable) bucketDepth(b bucket) int {
+	return min(commonPrefixLength(b.prefix, t.thisNodeInfo.NodeId), b.prefixLength)
+}
+
+// bucketLimit returns how many entries b may hold in the layout of the table.
+func (t *routingTable) bucketLimit(b bucket) int {
+	if !t.layout.ExtendedBuckets {
+		return t.bucketSize
+	}
+
+	var levels = t.bucketDepth(t.table[len(t.table)-1]) - t.bucketDepth(b)
+	if levels < len(extendedBucketFactors) {
+		return t.bucketSize * extendedBucketFactors[levels]
+	}
+	return t.bucketSize
+}
+
+// resizeBuckets updates the limits of all buckets, which change as the table splits. A bucket that ends up with more
+// entries than its new limit keeps them, but takes no new ones until it is back under the limit.
+func (t *routingTable) resizeBuckets() {
+	for i := range t.table {
+		t.table[i].bucketSize = t.bucketLimit(t.table[i])
+	}
+}
+
+// setLayout changes the layout of a table that is still empty.
+func (t *routingTable) setLayout(layout RoutingTableLayout) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.layout = layout
+	t.resizeBuckets()
+}
+
+// canSplit reports whether the full bucket at index may be split to make room for id.
+func (t *routingTable) canSplit(index int, id NodeId) bool {
+	if index == len(t.table)-1 {
+		return true
+	}
+	if !t.layout.RelaxedSplit || t.table[index].prefixLength >= 160 {
+		return false
+	}
+
+	var own = t.thisNodeInfo.NodeId
+	var distance = own.Distance(id)
+	var closer = 0
+	for _, bucket := range t.table {
+		for _, entry := range bucket.entries {
+			if own.Distance(entry.NodeId).Less(distance) {
+				closer++
+			}
+		}
+	}
+	return closer < t.bucketSize
+}
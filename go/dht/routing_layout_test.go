@@ -0,0 +1,139 @@
+package dht
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// newLayoutTestTable returns a table with our own id 00... and k = 2 in the given layout, filled with nodes whose ids
+// start with the given bytes.
+func newLayoutTestTable(layout RoutingTableLayout, prefixes ...byte) *routingTable {
+	var table = newRoutingTable(2, NodeInfo{})
+	table.setLayout(layout)
+	for _, prefix := range prefixes {
+		table.addEntry(NodeInfo{NodeId: NodeId{prefix}}, contactResponded)
+	}
+	return table
+}
+
+func capacities(table *routingTable) (result []int) {
+	for _, bucket := range table.stats().Buckets {
+		result = append(result, bucket.Capacity)
+	}
+	return result
+}
+
+func TestStandardLayout(t *testing.T) {
+	// 90 falls into the full bucket 1*, which does not contain our own id, so it is dropped.
+	var table = newLayoutTestTable(RoutingTableLayout{}, 0x80, 0xc0, 0x90)
+	if len(table.table) != 2 || table.table[0].containsNodeId(NodeId{0x90}) {
+		t.Error("Expected only the bucket containing our own id to split, got", table.table)
+	}
+	if c := capacities(table); c[0] != 2 || c[1] != 2 {
+		t.Error("Expected all buckets to hold k nodes, got", c)
+	}
+}
+
+func TestRelaxedSplitLayout(t *testing.T) {
+	// 90 would be one of the 2 closest nodes to our own id, so the bucket 1* splits into 11* and 10*.
+	var table = newLayoutTestTable(RoutingTableLayout{RelaxedSplit: true}, 0x80, 0xc0, 0x90)
+	var snapshot = table.snapshot()
+	if len(snapshot.Buckets) != 3 || snapshot.Buckets[0].Prefix != "11" || snapshot.Buckets[1].Prefix != "10" ||
+		snapshot.Buckets[2].Prefix != "0" {
+		t.Fatal("Expected buckets 11*, 10* and 0*, got", snapshot.Buckets)
+	}
+	if !table.table[1].containsNodeId(NodeId{0x90}) {
+		t.Error("Expected 90 to be added to bucket 10*")
+	}
+
+	// e0 is further away than the 2 closest nodes, so bucket 11* stays as it is once it is full.
+	table.addEntry(NodeInfo{NodeId: NodeId{0xf0}}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: NodeId{0xe0}}, contactResponded)
+	if len(table.table) != 3 || table.table[0].containsNodeId(NodeId{0xe0}) {
+		t.Error("Expected buckets outside the closest region not to split, got", table.table)
+	}
+
+	if s := table.stats(); s.Buckets[0].PrefixLength != 0 || s.Buckets[1].PrefixLength != 0 || s.Buckets[2].PrefixLength != 1 {
+		t.Error("Expected both halves of 1* to share 0 bits with our own id, got", s.Buckets)
+	}
+}
+
+func TestExtendedBucketsLayout(t *testing.T) {
+	var empty = newLayoutTestTable(RoutingTableLayout{ExtendedBuckets: true})
+	if c := capacities(empty); c[0] != 8 {
+		t.Error("Expected the only bucket to hold 4k nodes, got", c)
+	}
+
+	// The first bucket takes 8 nodes before it splits, after which the bucket split off holds 2k.
+	var table = newLayoutTestTable(RoutingTableLayout{ExtendedBuckets: true}, 0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87)
+	if len(table.table) != 1 || len(table.table[0].entries) != 8 {
+		t.Fatal("Expected 8 nodes in a single bucket, got", table.table)
+	}
+
+	table.addEntry(NodeInfo{NodeId: NodeId{0x40}}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: NodeId{0x20}}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: NodeId{0x10}}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: NodeId{0x08}}, contactResponded)
+	if c := capacities(table); len(c) != 2 || c[0] != 4 || c[1] != 8 {
+		t.Error("Expected capacities 2k and 4k, got", c)
+	}
+
+	// The bucket split off keeps its 8 nodes, but takes no more until it is under its limit.
+	table.addEntry(NodeInfo{NodeId: NodeId{0x88}}, contactResponded)
+	if len(table.table[0].entries) != 8 || table.table[0].containsNodeId(NodeId{0x88}) {
+		t.Error("Expected the bucket over its limit to take no new nodes, got", table.table[0].entries)
+	}
+
+	// Buckets further away than the second level hold k nodes.
+	for i := range 32 {
+		table.addEntry(NodeInfo{NodeId: NodeId{0x04, byte(i)}}, contactResponded)
+		table.addEntry(NodeInfo{NodeId: NodeId{0x01, byte(i)}}, contactResponded)
+	}
+	var c = capacities(table)
+	if len(c) < 4 || c[0] != 2 || c[len(c)-3] != 2 || c[len(c)-2] != 4 || c[len(c)-1] != 8 {
+		t.Error("Expected capacities k, ..., k, 2k, 4k, got", c)
+	}
+}
+
+func TestLayoutsKeepBucketsInRange(t *testing.T) {
+	var random = rand.New(rand.NewPCG(48, 48))
+	var layouts = []RoutingTableLayout{{}, {ExtendedBuckets: true}, {RelaxedSplit: true}, {ExtendedBuckets: true, RelaxedSplit: true}}
+	for _, layout := range layouts {
+		var ownId NodeId
+		for i := range ownId {
+			ownId[i] = byte(random.UintN(256))
+		}
+
+		var table = newRoutingTable(3, NodeInfo{NodeId: ownId})
+		table.setLayout(layout)
+		for range 2000 {
+			var id NodeId
+			for i := range id {
+				id[i] = byte(random.UintN(256))
+			}
+			// Share a prefix with our own id now and then, so that the table splits deeply.
+			copy(id[:random.IntN(3)], ownId[:])
+			table.addEntry(NodeInfo{NodeId: id}, contactResponded)
+		}
+
+		if !table.table[len(table.table)-1].contains(ownId) {
+			t.Error("Expected the last bucket to contain our own id in layout", layout)
+		}
+		for i, bucket := range table.table {
+			for _, entry := range bucket.entries {
+				if !bucket.contains(entry.NodeId) {
+					t.Error("Expected bucket", i, "to contain", entry.NodeId, "in layout", layout)
+				}
+			}
+
+			// Buckets are ordered by decreasing distance to our own id.
+			if i > 0 {
+				var previous, _ = table.table[i-1].idRange()
+				var first, _ = bucket.idRange()
+				if !ownId.Distance(first).Less(ownId.Distance(previous)) {
+					t.Error("Expected bucket", i, "to be closer to our own id than bucket", i-1, "in layout", layout)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package dht
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// RoutingTableSnapshot is a copy of the routing table at one point in time, meant to be exported for diffing and
+// visualisation. Ids are hex encoded and prefixes are written as strings of bits.
+type RoutingTableSnapshot struct {
+	NodeId     string           `json:"node_id"`
+	Address    string           `json:"address"`
+	BucketSize int              `json:"bucket_size"`
+	Time       time.Time        `json:"time"`
+	Buckets    []BucketSnapshot `json:"buckets"`
+}
+
+// BucketSnapshot is a bucket of the routing table, covering the ids from First to Last, which all start with Prefix.
+type BucketSnapshot struct {
+	Prefix   string          `json:"prefix"`
+	First    string          `json:"first"`
+	Last     string          `json:"last"`
+	Capacity int             `json:"capacity"`
+	Entries  []EntrySnapshot `json:"entries"`
+}
+
+type EntrySnapshot struct {
+	NodeId        string     `json:"node_id"`
+	Address       string     `json:"address"`
+	Status        string     `json:"status"`
+	Added         time.Time  `json:"added"`
+	LastSeen      *time.Time `json:"last_seen,omitempty"`
+	LastResponded *time.Time `json:"last_responded,omitempty"`
+	FailedQueries int        `json:"failed_queries"`
+}
+
+// prefixBits writes the first length bits of id as a string of zeros and ones.
+func prefixBits(id NodeId, length int) string {
+	var builder strings.Builder
+	for i := range length {
+		if id.isBitSet(i) {
+			builder.WriteByte('1')
+		} else {
+			builder.WriteByte('0')
+		}
+	}
+	return builder.String()
+}
+
+func (t *routingTable) snapshot() RoutingTableSnapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var now = t.now()
+	var result = RoutingTableSnapshot{
+		NodeId:     t.thisNodeInfo.NodeId.String(),
+		Address:    t.thisNodeInfo.Address.String(),
+		BucketSize: t.bucketSize,
+		Time:       now,
+		Buckets:    make([]BucketSnapshot, len(t.table)),
+	}
+
+	for i, bucket := range t.table {
+		var first, last = bucket.idRange()
+
+		var entries = make([]EntrySnapshot, len(bucket.entries))
+		for j, entry := range bucket.entries {
+			entries[j] = EntrySnapshot{
+				NodeId:        entry.NodeId.String(),
+				Address:       entry.Address.String(),
+				Status:        entry.status(now).String(),
+				Added:         entry.added,
+				LastSeen:      optionalTime(entry.lastSeen),
+				LastResponded: optionalTime(entry.lastResponded),
+				FailedQueries: entry.failedQueries,
+			}
+		}
+
+		result.Buckets[i] = BucketSnapshot{
+			Prefix:   prefixBits(bucket.prefix, bucket.prefixLength),
+			First:    first.String(),
+			Last:     last.String(),
+			Capacity: bucket.bucketSize,
+			Entries:  entries,
+		}
+	}
+
+	return result
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// WriteJSON writes the snapshot as indented JSON.
+func (s RoutingTableSnapshot) WriteJSON(w io.Writer) error {
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s); err != nil {
+		return fmt.Errorf("writing routing table as JSON: %w", err)
+	}
+	return nil
+}
+
+// WriteDOT writes the snapshot as a Graphviz graph of the split tree: every split is an inner node, whose branches
+// lead to the two halves of the id range it covers, and the buckets are the leaves.
+func (s RoutingTableSnapshot) WriteDOT(w io.Writer) error {
+	var builder strings.Builder
+	builder.WriteString("digraph routing_table {\n")
+	builder.WriteString("  node [shape=box, fontname=monospace];\n")
+	if len(s.Buckets) > 1 {
+		builder.WriteString("  split_ [shape=ellipse, label=\"root\"];\n")
+	}
+
+	var splits = map[string]bool{"": true}
+	for i, bucket := range s.Buckets {
+		var label = fmt.Sprintf("%s*\\n%d/%d nodes", bucket.Prefix, len(bucket.Entries), bucket.Capacity)
+		for _, entry := range bucket.Entries {
+			label += fmt.Sprintf("\\n%s %s %s", entry.NodeId, entry.Address, entry.Status)
+		}
+		fmt.Fprintf(&builder, "  bucket%d [label=\"%s\"];\n", i, label)
+
+		// Buckets share the splits along their prefixes, so each split is only written once.
+		for j := 1; j < len(bucket.Prefix); j++ {
+			var prefix = bucket.Prefix[:j]
+			if splits[prefix] {
+				continue
+			}
+			splits[prefix] = true
+			fmt.Fprintf(&builder, "  split_%s [shape=ellipse, label=\"%s*\"];\n", prefix, prefix)
+			fmt.Fprintf(&builder, "  split_%s -> split_%s [label=\"%c\"];\n", prefix[:j-1], prefix, prefix[j-1])
+		}
+		if len(bucket.Prefix) > 0 {
+			var parent = bucket.Prefix[:len(bucket.Prefix)-1]
+			fmt.Fprintf(&builder, "  split_%s -> bucket%d [label=\"%c\"];\n", parent, i, bucket.Prefix[len(parent)])
+		}
+	}
+
+	builder.WriteString("}\n")
+	if _, err := io.WriteString(w, builder.String()); err != nil {
+		return fmt.Errorf("writing routing table as DOT: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package dht
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoutingTableSnapshot(t *testing.T) {
+	var now = time.Unix(1700000000, 0).UTC()
+	var table = newRoutingTable(2, NodeInfo{})
+	table.now = func() time.Time { return now }
+
+	var address = func(port int) net.UDPAddr { return net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: port} }
+	table.addEntry(NodeInfo{NodeId: NodeId{0xff}, Address: address(1)}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: NodeId{0x80}, Address: address(2)}, contactSeen)
+	table.addEntry(NodeInfo{NodeId: NodeId{0x70}, Address: address(3)}, contactResponded)
+
+	var snapshot = table.snapshot()
+	if len(snapshot.Buckets) != 2 {
+		t.Fatal("Expected 2 buckets, got", snapshot.Buckets)
+	}
+
+	var far, near = snapshot.Buckets[0], snapshot.Buckets[1]
+	if far.Prefix != "1" || far.First != "8000000000000000000000000000000000000000" || far.Last != "ffffffffffffffffffffffffffffffffffffffff" {
+		t.Error("Expected the bucket that was split off to cover 8000... to ffff..., got", far.Prefix, far.First, far.Last)
+	}
+	if near.Prefix != "0" || near.First != "0000000000000000000000000000000000000000" || near.Last != "7fffffffffffffffffffffffffffffffffffffff" {
+		t.Error("Expected our own bucket to cover 0000... to 7fff..., got", near.Prefix, near.First, near.Last)
+	}
+
+	if len(far.Entries) != 2 || far.Entries[1].Address != "1.2.3.4:2" || far.Entries[1].Status != "questionable" ||
+		far.Entries[1].LastSeen == nil || far.Entries[1].LastResponded != nil {
+		t.Error("Expected the second entry to only have queried us, got", far.Entries)
+	}
+
+	var buffer bytes.Buffer
+	if err := snapshot.WriteJSON(&buffer); err != nil {
+		t.Fatal("Expected JSON export to succeed, got", err)
+	}
+	var decoded RoutingTableSnapshot
+	if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil || !decoded.Time.Equal(now) || len(decoded.Buckets) != 2 ||
+		decoded.Buckets[0].Entries[0].NodeId != "ff00000000000000000000000000000000000000" {
+		t.Error("Expected JSON to round-trip, got", decoded, "err:", err)
+	}
+
+	buffer.Reset()
+	if err := snapshot.WriteDOT(&buffer); err != nil {
+		t.Fatal("Expected DOT export to succeed, got", err)
+	}
+	var dot = buffer.String()
+	for _, expected := range []string{"digraph routing_table {", "split_ -> bucket0 [label=\"1\"]", "split_ -> bucket1 [label=\"0\"]"} {
+		if !strings.Contains(dot, expected) {
+			t.Error("Expected DOT output to contain", expected, "got", dot)
+		}
+	}
+}
+
+func TestBucketPrefixDeepTable(t *testing.T) {
+	var ownId, _ = hexStringToNodeId("a500000000000000000000000000000000000000")
+	var table = newRoutingTable(1, NodeInfo{NodeId: ownId})
+	table.addEntry(NodeInfo{NodeId: NodeId{0xa4}}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: NodeId{0xa5, 0x01}}, contactResponded)
+
+	var snapshot = table.snapshot()
+	if len(snapshot.Buckets) != 9 {
+		t.Fatal("Expected the table to split into 9 buckets, got", len(snapshot.Buckets))
+	}
+	if b := snapshot.Buckets[7]; b.Prefix != "10100100" || b.First != "a400000000000000000000000000000000000000" {
+		t.Error("Expected bucket 7 to hold the ids starting with a4, got", b.Prefix, b.First)
+	}
+	if b := snapshot.Buckets[8]; b.Prefix != "10100101" || b.Last != "a5ffffffffffffffffffffffffffffffffffffff" {
+		t.Error("Expected the last bucket to hold the ids starting with a5, got", b.Prefix, b.Last)
+	}
+
+	var buffer bytes.Buffer
+	snapshot.WriteDOT(&buffer)
+	for _, expected := range []string{"split_1010010 -> bucket7 [label=\"0\"]", "split_1010010 -> bucket8 [label=\"1\"]"} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Error("Expected DOT output to contain", expected, "got", buffer.String())
+		}
+	}
+}
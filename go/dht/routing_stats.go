@@ -0,0 +1,110 @@
+package dht
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// networkSizeSamples is how many of the nodes closest to our own id the network size is estimated from.
+const networkSizeSamples = 8
+
+// BucketStats describes the nodes in one bucket of the routing table.
+type BucketStats struct {
+	// PrefixLength is how many leading bits the nodes share with our own id. The last bucket holds all nodes that
+	// share at least that many. With RoutingTableLayout.RelaxedSplit, neighbouring buckets may have the same length.
+	PrefixLength int
+	// Capacity is how many nodes the bucket holds when it is full.
+	Capacity     int
+	Entries      int
+	Good         int
+	Questionable int
+	Bad          int
+	// AverageAge is how long the nodes have been in the table on average.
+	AverageAge time.Duration
+}
+
+// RoutingTableStats describes the routing table as a whole, bucket by bucket.
+type RoutingTableStats struct {
+	Buckets      []BucketStats
+	Entries      int
+	Good         int
+	Questionable int
+	Bad          int
+	AverageAge   time.Duration
+	// EstimatedNetworkSize is the number of nodes in the DHT, as estimated from how closely the nodes nearest to our
+	// own id are packed. It is 0 while the table is empty.
+	EstimatedNetworkSize int64
+}
+
+func (t *routingTable) stats() RoutingTableStats {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var now = t.now()
+	var result = RoutingTableStats{Buckets: make([]BucketStats, len(t.table))}
+	var totalAge time.Duration
+	for i, bucket := range t.table {
+		var bucketStats = BucketStats{
+			PrefixLength: t.bucketDepth(bucket),
+			Capacity:     bucket.bucketSize,
+			Entries:      len(bucket.entries),
+		}
+		var bucketAge time.Duration
+		for _, entry := range bucket.entries {
+			switch entry.status(now) {
+			case nodeGood:
+				bucketStats.Good++
+			case nodeQuestionable:
+				bucketStats.Questionable++
+			case nodeBad:
+				bucketStats.Bad++
+			}
+			bucketAge += now.Sub(entry.added)
+		}
+
+		if bucketStats.Entries > 0 {
+			bucketStats.AverageAge = bucketAge / time.Duration(bucketStats.Entries)
+		}
+		result.Buckets[i] = bucketStats
+
+		result.Entries += bucketStats.Entries
+		result.Good += bucketStats.Good
+		result.Questionable += bucketStats.Questionable
+		result.Bad += bucketStats.Bad
+		totalAge += bucketAge
+	}
+
+	if result.Entries > 0 {
+		result.AverageAge = totalAge / time.Duration(result.Entries)
+	}
+	result.EstimatedNetworkSize = t.estimateNetworkSize()
+	return result
+}
+
+// estimateNetworkSize assumes node ids are spread uniformly, so that the i-th closest of N nodes is expected at a
+// distance of i/N of the id space. The closest buckets are the ones we know completely, so a least squares fit of
+// this over the nodes closest to our own id gives N. Must be called with the lock held.
+func (t *routingTable) estimateNetworkSize() int64 {
+	var nodes []NodeInfo
+	for _, bucket := range t.table {
+		nodes = append(nodes, bucket.nodeInfos()...)
+	}
+	sortByDistance(nodes, t.thisNodeInfo.NodeId)
+	nodes = nodes[:min(networkSizeSamples, len(nodes))]
+
+	var sumSquares, sumWeighted float64
+	for i, node := range nodes {
+		var distance = t.thisNodeInfo.NodeId.Distance(node.NodeId)
+		// The first 64 bits of the distance are plenty for a fraction of the id space.
+		var fraction = float64(binary.BigEndian.Uint64(distance[:8])) / math.Exp2(64)
+		var rank = float64(i + 1)
+		sumSquares += rank * rank
+		sumWeighted += rank * fraction
+	}
+
+	if sumWeighted == 0 {
+		return 0
+	}
+	return int64(math.Round(sumSquares / sumWeighted))
+}
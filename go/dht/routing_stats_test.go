@@ -0,0 +1,64 @@
+package dht
+
+import (
+	"math/rand/v2"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRoutingTableStats(t *testing.T) {
+	var now = time.Unix(1700000000, 0)
+	var table = newRoutingTable(2, NodeInfo{})
+	table.now = func() time.Time { return now }
+
+	if stats := table.stats(); stats.Entries != 0 || stats.AverageAge != 0 || stats.EstimatedNetworkSize != 0 {
+		t.Error("Expected an empty table to have empty stats, got", stats)
+	}
+
+	var address = func(port int) net.UDPAddr { return net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: port} }
+	table.addEntry(NodeInfo{NodeId: NodeId{0xf1}, Address: address(1)}, contactResponded)
+	now = now.Add(10 * time.Minute)
+	table.addEntry(NodeInfo{NodeId: NodeId{0xf2}, Address: address(2)}, contactSeen)
+	table.addEntry(NodeInfo{NodeId: NodeId{0x01}, Address: address(3)}, contactResponded)
+	for range badAfterFailures {
+		table.markFailed(address(3))
+	}
+
+	var stats = table.stats()
+	if len(stats.Buckets) != 2 {
+		t.Fatal("Expected 2 buckets, got", stats.Buckets)
+	}
+	if b := stats.Buckets[0]; b.PrefixLength != 0 || b.Entries != 2 || b.Good != 1 || b.Questionable != 1 || b.AverageAge != 5*time.Minute {
+		t.Error("Expected bucket 0 to have a good and a questionable node, 5 minutes old on average, got", b)
+	}
+	if b := stats.Buckets[1]; b.PrefixLength != 1 || b.Entries != 1 || b.Bad != 1 || b.AverageAge != 0 {
+		t.Error("Expected bucket 1 to have a new bad node, got", b)
+	}
+	if stats.Entries != 3 || stats.Good != 1 || stats.Questionable != 1 || stats.Bad != 1 || stats.AverageAge != 200*time.Second {
+		t.Error("Expected totals over all buckets, got", stats)
+	}
+}
+
+func TestEstimateNetworkSize(t *testing.T) {
+	var random = rand.New(rand.NewPCG(44, 44))
+	var randomId = func() (id NodeId) {
+		for i := range id {
+			id[i] = byte(random.UintN(256))
+		}
+		return id
+	}
+
+	for _, size := range []int{1000, 100000} {
+		var table = newRoutingTable(8, NodeInfo{NodeId: randomId()})
+		for range size {
+			table.addEntry(NodeInfo{NodeId: randomId()}, contactResponded)
+		}
+
+		// With only 8 samples, the estimate is rough, so just check it has the right order of magnitude.
+		var estimate = table.stats().EstimatedNetworkSize
+		if estimate < int64(size)/3 || estimate > int64(size)*3 {
+			t.Error("Expected an estimate close to", size, "got", estimate)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package dht
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+type routingTable struct {
+	thisNodeInfo NodeInfo
+	bucketSize   int
+	layout       RoutingTableLayout
+	// table holds buckets that cover the id space without overlap, ordered by decreasing distance to our own id. The
+	// last bucket contains our own id.
+	table        []bucket
+	filter       *IPFilter
+	maxPerIP     int
+	maxPerPrefix int
+	now          func() time.Time
+	lock         sync.RWMutex
+}
+
+func newRoutingTable(bucketSize int, thisNodeInfo NodeInfo) *routingTable {
+	// Technically we don't need all 160 buckets, since there are only 8 nodes with common
+	// longest prefix length of 157, so with a bucket size of 8, bucket 157 will never be split.
+	var initialTable = make([]bucket, 0, 160)
+	initialTable = append(initialTable, newBucket(bucketSize))
+
+	return &routingTable{
+		thisNodeInfo: thisNodeInfo,
+		bucketSize:   bucketSize,
+		table:        initialTable,
+		now:          time.Now,
+		lock:         sync.RWMutex{},
+	}
+}
+
+// emptyCopy returns an empty table for another own node id, with the same bucket size, layout and limits as t.
+func (t *routingTable) emptyCopy(thisNodeInfo NodeInfo) *routingTable {
+	var table = newRoutingTable(t.bucketSize, thisNodeInfo)
+	table.setLayout(t.layout)
+	table.filter = t.filter
+	table.maxPerIP = t.maxPerIP
+	table.maxPerPrefix = t.maxPerPrefix
+	return table
+}
+
+// addResult tells what became of an entry passed to addEntry.
+type addResult struct {
+	// added is also true if the entry was already in the table.
+	added bool
+	// event is set if the entry was rejected for a reason worth monitoring.
+	event RoutingEventType
+	// existing is the entry already in the table, for EventAddressChange.
+	existing NodeInfo
+	// evictionCandidate is set if the entry did not fit into a full bucket, but the least recently seen node of that
+	// bucket is questionable. If it fails to respond to a ping, it turns bad and can be replaced.
+	evictionCandidate *NodeInfo
+}
+
+// contactKind is how we heard from a node.
+type contactKind int
+
+const (
+	// contactSeen is a node that sent us a query.
+	contactSeen contactKind = iota
+	// contactResponded is a node that responded to one of our queries.
+	contactResponded
+)
+
+// addEntry adds a node we heard from to the table, or updates when we last heard from it if it is already known.
+func (t *routingTable) addEntry(node NodeInfo, kind contactKind) addResult {
+	if t.filter.Blocked(node.Address.IP) {
+		return addResult{}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var entry = routingEntry{NodeInfo: node, added: t.now()}
+	if kind == contactResponded {
+		entry.lastResponded = t.now()
+	} else {
+		entry.lastSeen = t.now()
+	}
+
+	return t.addEntryRec(entry)
+}
+
+func (t *routingTable) addEntryRec(entry routingEntry) addResult {
+	var bucketIndex = t.bucketIndex(entry.NodeId)
+	var bucket = t.table[bucketIndex]
+
+	if i := bucket.indexOf(entry.NodeId); i >= 0 {
+		var existing = bucket.entries[i]
+		if !existing.Address.IP.Equal(entry.Address.IP) || existing.Address.Port != entry.Address.Port {
+			return addResult{event: EventAddressChange, existing: existing.NodeInfo}
+		}
+
+		t.table[bucketIndex], _ = bucket.add(entry)
+		return addResult{added: true}
+	}
+
+	if len(bucket.entries) < bucket.bucketSize {
+		if event := bucket.checkLimits(entry.NodeInfo, t.maxPerIP, t.maxPerPrefix); event != 0 {
+			return addResult{event: event}
+		}
+
+		t.table[bucketIndex], _ = bucket.add(entry)
+		return addResult{added: true}
+	}
+
+	// At this point we know that the bucket is full, so check if we can split it. If not, the new entry can only
+	// replace a bad node.
+	if !t.canSplit(bucketIndex, entry.NodeId) {
+		return t.replaceBadEntry(bucketIndex, entry)
+	}
+
+	// Split the bucket by the next bit of its prefix. The half that shares this bit with our own id is closer to us, so
+	// it goes after the other one. For the bucket containing our own id, this appends to the table, thereby extending
+	// the prefix length the routing table covers.
+	var zeroBucket, oneBucket = bucket.splitAt(bucket.prefixLength)
+	var far, near = oneBucket, zeroBucket
+	if t.thisNodeInfo.NodeId.isBitSet(bucket.prefixLength) {
+		far, near = zeroBucket, oneBucket
+	}
+	t.table[bucketIndex] = far
+	t.table = slices.Insert(t.table, bucketIndex+1, near)
+	t.resizeBuckets()
+
+	// Now that we set up the new buckets, we can try to add the entry again.
+	return t.addEntryRec(entry)
+}
+
+// bucketIndex returns the index of the bucket whose range contains id.
+func (t *routingTable) bucketIndex(id NodeId) int {
+	for i := len(t.table) - 1; i > 0; i-- {
+		if t.table[i].contains(id) {
+			return i
+		}
+	}
+	return 0
+}
+
+// replaceBadEntry puts entry in the place of the least recently seen bad node of a full bucket. Only nodes that
+// responded to us qualify, so that nodes which merely query us cannot push out others.
+func (t *routingTable) replaceBadEntry(bucketIndex int, entry routingEntry) addResult {
+	if entry.lastResponded.IsZero() {
+		return addResult{}
+	}
+
+	var bucket = t.table[bucketIndex]
+	for i, existing := range bucket.entries {
+		if existing.status(t.now()) != nodeBad {
+			continue
+		}
+
+		// The limits apply to the bucket without the node that is replaced.
+		var without = bucket
+		without.entries = append(bucket.entries[:i:i], bucket.entries[i+1:]...)
+		if event := without.checkLimits(entry.NodeInfo, t.maxPerIP, t.maxPerPrefix); event != 0 {
+			return addResult{event: event}
+		}
+
+		without.entries = append(without.entries, entry)
+		t.table[bucketIndex] = without
+		return addResult{added: true}
+	}
+
+	if head, ok := bucket.evictionCandidate(); ok && head.status(t.now()) == nodeQuestionable {
+		return addResult{evictionCandidate: &head.NodeInfo}
+	}
+	return addResult{}
+}
+
+// markFailed records that the node at address did not respond to a query.
+func (t *routingTable) markFailed(address net.UDPAddr) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, bucket := range t.table {
+		for i := range bucket.entries {
+			if bucket.entries[i].Address.IP.Equal(address.IP) && bucket.entries[i].Address.Port == address.Port {
+				bucket.entries[i].failedQueries++
+				return
+			}
+		}
+	}
+}
+
+// markBad makes the entry with the given id bad, so that the next node for its bucket replaces it.
+func (t *routingTable) markBad(id NodeId) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, bucket := range t.table {
+		if i := bucket.indexOf(id); i >= 0 {
+			bucket.entries[i].failedQueries = max(bucket.entries[i].failedQueries, badAfterFailures)
+			return
+		}
+	}
+}
+
+// updateAddress changes the address of the entry with the given id after a verified address change, which also
+// counts as activity of the node.
+func (t *routingTable) updateAddress(id NodeId, address net.UDPAddr) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, bucket := range t.table {
+		if i := bucket.indexOf(id); i >= 0 {
+			var entry = bucket.entries[i]
+			entry.Address = address
+			entry.lastSeen = t.now()
+			bucket.moveToTail(i, entry)
+			return
+		}
+	}
+}
+
+// lastResponded returns when the node at address last answered one of our queries, or the zero time if it never did
+// or is not in the table.
+func (t *routingTable) lastResponded(address net.UDPAddr) time.Time {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for _, bucket := range t.table {
+		for _, entry := range bucket.entries {
+			if entry.Address.IP.Equal(address.IP) && entry.Address.Port == address.Port {
+				return entry.lastResponded
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (t *routingTable) findNode(targetId NodeId) (result []NodeInfo, exactMatch bool) {
+	t.lock.RLock()
+	var thisNodeInfo = t.thisNodeInfo
+	t.lock.RUnlock()
+
+	if thisNodeInfo.NodeId.isEqual(targetId) {
+		return []NodeInfo{thisNodeInfo}, true
+	}
+
+	return t.findNodeWithoutSelf(targetId)
+}
+
+// findNodeWithoutSelf returns the node with targetId if it is in the table, or else the closest nodes to it.
+func (t *routingTable) findNodeWithoutSelf(targetId NodeId) (result []NodeInfo, exactMatch bool) {
+	result = t.closestNodes(targetId, t.bucketSize)
+	if len(result) > 0 && result[0].NodeId.isEqual(targetId) {
+		return result[:1], true
+	}
+
+	return result, false
+}
+
+// closestNodes returns the k nodes closest to target from all buckets, sorted by their distance to target.
+func (t *routingTable) closestNodes(target NodeId, k int) []NodeInfo {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var result []NodeInfo
+	for _, bucket := range t.table {
+		for _, entry := range bucket.entries {
+			result = append(result, entry.NodeInfo)
+		}
+	}
+
+	sortByDistance(result, target)
+	return result[:min(k, len(result))]
+}
+
+func (t *routingTable) setOwnAddress(address net.UDPAddr) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.thisNodeInfo.Address = address
+}
+
+func printRoutingTable(table *routingTable) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+
+	for i, bucket := range table.table {
+		fmt.Printf("%3d: %s", i, bucket)
+		fmt.Println()
+	}
+}
@@ -0,0 +1,275 @@
+package dht
+
+import (
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRoutingTableAddEntry(t *testing.T) {
+	var ownId, _ = hexStringToNodeId("0000000000000000000000000000000000000000")
+	var distantId1, _ = hexStringToNodeId("ffffffffffffffffffffffffffffffffffffffff")
+	var distantId2, _ = hexStringToNodeId("8000000000000000000000000000000000000000")
+	var distantId3, _ = hexStringToNodeId("ffffffffffffff00000000000000000000000000")
+	var nearId1, _ = hexStringToNodeId("7000000000000000000000000000000000000000")
+	var nearId2, _ = hexStringToNodeId("0000000000ffffffffffffffffffffffffffffff")
+
+	var table = newRoutingTable(2, NodeInfo{NodeId: ownId})
+	table.addEntry(NodeInfo{NodeId: distantId1}, contactResponded)
+	var sizeBeforeDuplicateAdded = len(table.table[0].entries)
+	table.addEntry(NodeInfo{NodeId: distantId1}, contactResponded)
+
+	if len(table.table[0].entries) != sizeBeforeDuplicateAdded {
+		t.Error("Expected addEntry to not add duplicate entry")
+	}
+
+	table.addEntry(NodeInfo{NodeId: distantId2}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: distantId3}, contactResponded)
+
+	// Tree should be split at this point, with the latest distant node discarded
+	if len(table.table) <= 1 {
+		t.Error("Expected table to have more than one bucket")
+	}
+
+	if table.table[1].containsNodeId(distantId1) {
+		t.Error("Expected bucket with longer prefix to not contain distantId1")
+	}
+	if table.table[1].containsNodeId(distantId2) {
+		t.Error("Expected bucket with longer prefix to not contain distantId2")
+	}
+	if table.table[1].containsNodeId(distantId3) {
+		t.Error("Expected bucket with longer prefix to not contain distantId3")
+	}
+	if !table.table[0].containsNodeId(distantId1) {
+		t.Error("Expected bucket with shorter prefix to contain distantId1")
+	}
+	if !table.table[0].containsNodeId(distantId2) {
+		t.Error("Expected bucket with shorter prefix to contain distantId2")
+	}
+	if table.table[0].containsNodeId(distantId3) {
+		t.Error("Expected bucket with shorter prefix to not contain distantId3")
+	}
+
+	table.addEntry(NodeInfo{NodeId: nearId1}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: nearId2}, contactResponded)
+
+	if !table.table[1].containsNodeId(nearId1) {
+		t.Error("Expected bucket with longer prefix to contain nearId1")
+	}
+	if !table.table[1].containsNodeId(nearId2) {
+		t.Error("Expected bucket with longer prefix to contain nearId2")
+	}
+}
+
+func TestRoutingTableFindNode(t *testing.T) {
+	var ownId, _ = hexStringToNodeId("0000000000000000000000000000000000000000")
+	var nodeId1, _ = hexStringToNodeId("ffffffffffffffffffffffffffffffffffffffff")
+	var nodeId2, _ = hexStringToNodeId("0fffffffffffffffffffffffffffffffffffffff")
+	var nodeId3, _ = hexStringToNodeId("00ffffffffffffffffffffffffffffffffffffff")
+	var nodeId4, _ = hexStringToNodeId("000fffffffffffffffffffffffffffffffffffff")
+
+	var table = newRoutingTable(2, NodeInfo{NodeId: ownId})
+	table.addEntry(NodeInfo{NodeId: nodeId1}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: nodeId2}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: nodeId3}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: nodeId4}, contactResponded)
+
+	// At this point, the routing table looks something like this:
+	// 0: [nodeId1]
+	// 1: []
+	// 2: []
+	// 3: []
+	// 4: [nodeId2]
+	// 5: [nodeId3, nodeId4]
+
+	var result, exactMatch = table.findNode(nodeId1)
+	if !exactMatch || len(result) != 1 || !result[0].NodeId.isEqual(nodeId1) {
+		t.Error("Expected exact match")
+	}
+
+	result, exactMatch = table.findNode(nodeId4)
+	if !exactMatch || len(result) != 1 || !result[0].NodeId.isEqual(nodeId4) {
+		t.Error("Expected exact match")
+	}
+
+	var query, _ = hexStringToNodeId("faaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	result, exactMatch = table.findNode(query) // bucket 0
+	if exactMatch || len(result) != 2 || !result[0].NodeId.isEqual(nodeId1) || !result[1].NodeId.isEqual(nodeId2) {
+		t.Error("Expected one entry from bucket 0 and one from bucket 4, got: ", result)
+	}
+
+	query, _ = hexStringToNodeId("3aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	result, exactMatch = table.findNode(query) // bucket 2
+	if exactMatch || len(result) != 2 || !result[0].NodeId.isEqual(nodeId2) || !result[1].NodeId.isEqual(nodeId3) {
+		t.Error("Expected the closest entries from bucket 4 and 5, got: ", result)
+	}
+
+	query, _ = hexStringToNodeId("1faaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	result, exactMatch = table.findNode(query) // bucket 3
+	if exactMatch || len(result) != 2 || !result[0].NodeId.isEqual(nodeId2) || !result[1].NodeId.isEqual(nodeId3) {
+		t.Error("Expected one entry from bucket 4 and one from bucket 5, got: ", result)
+	}
+
+	query, _ = hexStringToNodeId("000000aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	result, exactMatch = table.findNode(query) // bucket 5
+	if exactMatch || len(result) != 2 || !result[0].NodeId.isEqual(nodeId4) || !result[1].NodeId.isEqual(nodeId3) {
+		t.Error("Expected all from bucket 5, closest first, got: ", result)
+	}
+}
+
+func TestRoutingTableFindOwnNode(t *testing.T) {
+	var ownId, _ = hexStringToNodeId("1234000000000000000000000000000000000000")
+	var nodeId1, _ = hexStringToNodeId("ffffffffffffffffffffffffffffffffffffffff")
+	var nodeId2, _ = hexStringToNodeId("0fffffffffffffffffffffffffffffffffffffff")
+	var table = newRoutingTable(2, NodeInfo{NodeId: ownId})
+	table.addEntry(NodeInfo{NodeId: nodeId1}, contactResponded)
+	table.addEntry(NodeInfo{NodeId: nodeId2}, contactResponded)
+
+	var result, exactMatch = table.findNode(ownId)
+	if !exactMatch || len(result) != 1 || !result[0].NodeId.isEqual(ownId) {
+		t.Error("Expected exact match with node entry, go:", result)
+	}
+
+	result, exactMatch = table.findNodeWithoutSelf(ownId)
+	if exactMatch || len(result) != 2 || result[0].NodeId.isEqual(ownId) || result[1].NodeId.isEqual(ownId) {
+		t.Error("Expected findNodeWithoutSelf to not match ownId, got: ", result)
+	}
+}
+
+func TestRoutingTableNodeStatus(t *testing.T) {
+	var now = time.Unix(1700000000, 0)
+	var table = newRoutingTable(2, NodeInfo{})
+	table.now = func() time.Time { return now }
+
+	var address = func(port int) net.UDPAddr { return net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: port} }
+	var seen = NodeInfo{NodeId: NodeId{0xf1}, Address: address(1)}
+	var responded = NodeInfo{NodeId: NodeId{0xf2}, Address: address(2)}
+	table.addEntry(seen, contactSeen)
+	table.addEntry(responded, contactResponded)
+
+	var statuses = func() (result []nodeStatus) {
+		for _, entry := range table.table[0].entries {
+			result = append(result, entry.status(now))
+		}
+		return result
+	}
+
+	if s := statuses(); s[0] != nodeQuestionable || s[1] != nodeGood {
+		t.Error("Expected a node that only queried us to be questionable and one that responded to be good, got", s)
+	}
+
+	// After 15 minutes, a query keeps a node good only if it has responded before.
+	now = now.Add(questionableAfter)
+	table.addEntry(seen, contactSeen)
+	table.addEntry(responded, contactSeen)
+	if s := statuses(); s[0] != nodeQuestionable || s[1] != nodeGood {
+		t.Error("Expected the responsive node to stay good, got", s)
+	}
+
+	// A full bucket keeps out new nodes, unless they responded and can replace a bad node.
+	var newcomer = NodeInfo{NodeId: NodeId{0xf3}, Address: address(3)}
+	for range badAfterFailures {
+		table.markFailed(responded.Address)
+	}
+	if result := table.addEntry(newcomer, contactSeen); result.added {
+		t.Error("Expected a node that only queried us not to replace a bad node")
+	}
+	if result := table.addEntry(newcomer, contactResponded); !result.added || table.table[0].entries[1].NodeId != newcomer.NodeId {
+		t.Error("Expected the responding node to replace the bad node, got", table.table[0].entries)
+	}
+
+	// Responding resets the failure count.
+	table.markFailed(newcomer.Address)
+	table.addEntry(newcomer, contactResponded)
+	if table.table[0].entries[1].failedQueries != 0 {
+		t.Error("Expected failures to be reset after a response")
+	}
+}
+
+func TestRoutingTableEvictionCandidate(t *testing.T) {
+	var now = time.Unix(1700000000, 0)
+	var table = newRoutingTable(2, NodeInfo{})
+	table.now = func() time.Time { return now }
+
+	var address = func(port int) net.UDPAddr { return net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: port} }
+	var first = NodeInfo{NodeId: NodeId{0xf1}, Address: address(1)}
+	var second = NodeInfo{NodeId: NodeId{0xf2}, Address: address(2)}
+	var newcomer = NodeInfo{NodeId: NodeId{0xf3}, Address: address(3)}
+	table.addEntry(first, contactResponded)
+	table.addEntry(second, contactResponded)
+
+	if result := table.addEntry(newcomer, contactResponded); result.added || result.evictionCandidate != nil {
+		t.Error("Expected no eviction candidate while all nodes are good, got", result)
+	}
+
+	// Once the nodes turn questionable, the least recently seen one is pinged first. Activity moves a node back.
+	now = now.Add(questionableAfter)
+	table.addEntry(first, contactSeen)
+	var result = table.addEntry(newcomer, contactResponded)
+	if result.added || result.evictionCandidate == nil || result.evictionCandidate.NodeId != second.NodeId {
+		t.Error("Expected the second node to be the eviction candidate, got", result)
+	}
+
+	// A verified address change counts as activity too.
+	table.updateAddress(second.NodeId, address(4))
+	if entries := lockedEntries(table); entries[1].NodeId != second.NodeId || entries[1].Address.Port != 4 {
+		t.Error("Expected the second node to move to the tail with its new address, got", entries)
+	}
+}
+
+func TestClosestNodesMatchesBruteForce(t *testing.T) {
+	var random = rand.New(rand.NewPCG(43, 43))
+	var randomId = func() (id NodeId) {
+		for i := range id {
+			id[i] = byte(random.UintN(256))
+		}
+		return id
+	}
+
+	for range 50 {
+		var table = newRoutingTable(1+random.IntN(8), NodeInfo{NodeId: randomId()})
+		for range random.IntN(300) {
+			var id = randomId()
+			// Share a prefix with our own id now and then, so that the table splits deeply.
+			copy(id[:random.IntN(4)], table.thisNodeInfo.NodeId[:])
+			table.addEntry(NodeInfo{NodeId: id}, contactResponded)
+		}
+
+		var all = table.closestNodes(NodeId{}, math.MaxInt)
+		for range 20 {
+			var target = randomId()
+			var k = random.IntN(20)
+
+			// The reference computes distances as big integers, independently of Distance.
+			var distance = func(id NodeId) *big.Int {
+				return new(big.Int).Xor(new(big.Int).SetBytes(id[:]), new(big.Int).SetBytes(target[:]))
+			}
+			var expected = slices.Clone(all)
+			slices.SortFunc(expected, func(a, b NodeInfo) int { return distance(a.NodeId).Cmp(distance(b.NodeId)) })
+			expected = expected[:min(k, len(expected))]
+
+			var result = table.closestNodes(target, k)
+			if !slices.Equal(nodeIds(result), nodeIds(expected)) {
+				t.Fatal("Expected closest nodes", nodeIds(expected), "got", nodeIds(result))
+			}
+
+			for i := 1; i < len(result); i++ {
+				if !result[i-1].NodeId.Distance(target).Less(result[i].NodeId.Distance(target)) {
+					t.Fatal("Expected strictly increasing distances, got", nodeIds(result))
+				}
+			}
+		}
+	}
+}
+
+func nodeIds(nodes []NodeInfo) []NodeId {
+	var result = make([]NodeId, len(nodes))
+	for i, node := range nodes {
+		result[i] = node.NodeId
+	}
+	return result
+}
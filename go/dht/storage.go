@@ -0,0 +1,276 @@
+package dht
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"dhtcli/krpc"
+)
+
+const peerExpiry = 30 * time.Minute
+const maxPeersPerInfoHash = 100
+const maxSamples = 20
+const itemExpiry = 2 * time.Hour
+const tokenRotationInterval = 5 * time.Minute
+
+// The stores are limited in total, since anyone with a token can announce and put as much as they like. Expired
+// entries are swept regularly, and when a store is full anyway a random entry makes room for the new one.
+const maxStoredPeers = 10000
+const maxStoredItems = 10000
+const storageSweepInterval = 5 * time.Minute
+
+// Peers announced to us via announce_peer
+
+type peerEntry struct {
+	address   net.UDPAddr
+	announced time.Time
+}
+
+type peerStore struct {
+	peers map[NodeId][]peerEntry
+	// count is the number of entries in all of peers.
+	count int
+	lock  sync.Mutex
+}
+
+func newPeerStore() *peerStore {
+	return &peerStore{
+		peers: make(map[NodeId][]peerEntry),
+	}
+}
+
+func (s *peerStore) addPeer(infoHash NodeId, address net.UDPAddr) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, entry := range s.peers[infoHash] {
+		if entry.address.IP.Equal(address.IP) && entry.address.Port == address.Port {
+			s.peers[infoHash][i].announced = time.Now()
+			return
+		}
+	}
+
+	if s.count >= maxStoredPeers {
+		s.removeExpiredLocked()
+	}
+	if s.count >= maxStoredPeers {
+		// Map iteration order is random, so this drops the oldest peer of a random infohash.
+		for other, entries := range s.peers {
+			s.setEntries(other, entries[1:])
+			break
+		}
+	}
+
+	var entries = s.peers[infoHash]
+	if len(entries) >= maxPeersPerInfoHash {
+		entries = entries[1:]
+	}
+	s.setEntries(infoHash, append(entries, peerEntry{address: address, announced: time.Now()}))
+}
+
+// setEntries replaces the peers of infoHash, keeping count up to date.
+func (s *peerStore) setEntries(infoHash NodeId, entries []peerEntry) {
+	s.count += len(entries) - len(s.peers[infoHash])
+	if len(entries) == 0 {
+		delete(s.peers, infoHash)
+	} else {
+		s.peers[infoHash] = entries
+	}
+}
+
+// removeExpired drops the peers that were not announced again in time.
+func (s *peerStore) removeExpired() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.removeExpiredLocked()
+}
+
+func (s *peerStore) removeExpiredLocked() {
+	for infoHash, entries := range s.peers {
+		s.setEntries(infoHash, slices.DeleteFunc(entries, func(entry peerEntry) bool {
+			return time.Since(entry.announced) >= peerExpiry
+		}))
+	}
+}
+
+func (s *peerStore) getPeers(infoHash NodeId) []net.UDPAddr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var entries = s.peers[infoHash]
+	var alive = entries[:0]
+	var result = make([]net.UDPAddr, 0, len(entries))
+
+	for _, entry := range entries {
+		if time.Since(entry.announced) < peerExpiry {
+			alive = append(alive, entry)
+			result = append(result, entry.address)
+		}
+	}
+
+	s.setEntries(infoHash, alive)
+	return result
+}
+
+// sample returns up to count of the infohashes with live peers, and the number of such infohashes in total.
+func (s *peerStore) sample(count int) (infoHashes []NodeId, total int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Map iteration order is random, which makes the first ones a random selection.
+	for infoHash, entries := range s.peers {
+		if !slices.ContainsFunc(entries, func(entry peerEntry) bool { return time.Since(entry.announced) < peerExpiry }) {
+			continue
+		}
+
+		total++
+		if len(infoHashes) < count {
+			infoHashes = append(infoHashes, infoHash)
+		}
+	}
+	return infoHashes, total
+}
+
+// BEP 44 items stored via put
+
+type itemEntry struct {
+	item   Item
+	stored time.Time
+}
+
+type itemStore struct {
+	items map[NodeId]itemEntry
+	lock  sync.Mutex
+}
+
+func newItemStore() *itemStore {
+	return &itemStore{
+		items: make(map[NodeId]itemEntry),
+	}
+}
+
+func (s *itemStore) get(target NodeId) (Item, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry, ok := s.items[target]
+	if !ok {
+		return Item{}, false
+	}
+
+	if time.Since(entry.stored) >= itemExpiry {
+		delete(s.items, target)
+		return Item{}, false
+	}
+
+	return entry.item, true
+}
+
+// put stores item, enforcing the sequence number and compare-and-swap rules for mutable items.
+func (s *itemStore) put(item Item, cas *int) *krpc.Error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var target = item.Target()
+	if existing, ok := s.items[target]; ok && item.IsMutable() {
+		if cas != nil && existing.item.Seq != *cas {
+			return &krpc.Error{Code: krpc.ErrorCasMismatch, Message: "CAS mismatch, re-read value and try again"}
+		}
+
+		if item.Seq < existing.item.Seq {
+			return &krpc.Error{Code: krpc.ErrorSequenceTooLow, Message: "Sequence number less than current"}
+		}
+	}
+
+	if _, exists := s.items[target]; !exists && len(s.items) >= maxStoredItems {
+		s.removeExpiredLocked()
+		// Map iteration order is random, so this drops a random item if none expired.
+		for other := range s.items {
+			if len(s.items) < maxStoredItems {
+				break
+			}
+			delete(s.items, other)
+		}
+	}
+
+	s.items[target] = itemEntry{item: item, stored: time.Now()}
+	return nil
+}
+
+// removeExpired drops the items that were not put again in time.
+func (s *itemStore) removeExpired() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.removeExpiredLocked()
+}
+
+func (s *itemStore) removeExpiredLocked() {
+	for target, entry := range s.items {
+		if time.Since(entry.stored) >= itemExpiry {
+			delete(s.items, target)
+		}
+	}
+}
+
+// Write tokens handed out in get_peers and get responses. A token is a hash of the requester's IP and a secret that
+// is rotated regularly; tokens made with the previous secret are still accepted.
+
+type tokenManager struct {
+	currentSecret  []byte
+	previousSecret []byte
+	rotatedAt      time.Time
+	lock           sync.Mutex
+}
+
+func newTokenManager() *tokenManager {
+	var secret = newTokenSecret()
+	return &tokenManager{
+		currentSecret:  secret,
+		previousSecret: secret,
+		rotatedAt:      time.Now(),
+	}
+}
+
+func newTokenSecret() []byte {
+	var secret = make([]byte, 16)
+	// crypto/rand.Read never returns an error
+	_, _ = rand.Read(secret)
+	return secret
+}
+
+func (m *tokenManager) rotateIfDue() {
+	if time.Since(m.rotatedAt) < tokenRotationInterval {
+		return
+	}
+
+	m.previousSecret = m.currentSecret
+	m.currentSecret = newTokenSecret()
+	m.rotatedAt = time.Now()
+}
+
+func makeToken(ip net.IP, secret []byte) string {
+	var hash = sha1.Sum(append([]byte(ip.To16()), secret...))
+	return string(hash[:8])
+}
+
+func (m *tokenManager) tokenFor(ip net.IP) string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.rotateIfDue()
+	return makeToken(ip, m.currentSecret)
+}
+
+func (m *tokenManager) isValid(token string, ip net.IP) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.rotateIfDue()
+	return subtle.ConstantTimeCompare([]byte(token), []byte(makeToken(ip, m.currentSecret))) == 1 ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(makeToken(ip, m.previousSecret))) == 1
+}
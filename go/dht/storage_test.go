@@ -0,0 +1,72 @@
+package dht
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"dhtcli/bencode"
+)
+
+// numberedId returns a distinct id for each i.
+func numberedId(i int) NodeId {
+	var id NodeId
+	binary.BigEndian.PutUint32(id[:], uint32(i))
+	return id
+}
+
+func TestPeerStoreLimits(t *testing.T) {
+	var store = newPeerStore()
+	var peer = net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 6881}
+
+	for i := range maxStoredPeers + 10 {
+		store.addPeer(numberedId(i), peer)
+	}
+	if store.count != maxStoredPeers || len(store.peers) != maxStoredPeers {
+		t.Error("Expected the store to stay at", maxStoredPeers, "peers, got", store.count, len(store.peers))
+	}
+	if peers := store.getPeers(numberedId(maxStoredPeers + 9)); len(peers) != 1 {
+		t.Error("Expected the latest announce to be stored, got", peers)
+	}
+
+	// Expired peers are swept without being asked for.
+	for infoHash := range store.peers {
+		store.peers[infoHash][0].announced = time.Now().Add(-peerExpiry)
+	}
+	store.removeExpired()
+	if store.count != 0 || len(store.peers) != 0 {
+		t.Error("Expected all expired peers to be removed, got", store.count, len(store.peers))
+	}
+}
+
+func TestItemStoreLimits(t *testing.T) {
+	var store = newItemStore()
+	var first = NewImmutableItem(bencode.Int(0))
+
+	for i := range maxStoredItems {
+		store.put(NewImmutableItem(bencode.Int(i)), nil)
+	}
+	var entry = store.items[first.Target()]
+	entry.stored = time.Now().Add(-itemExpiry)
+	store.items[first.Target()] = entry
+
+	// The expired item makes room for the new one.
+	var item = NewImmutableItem(bencode.String("new"))
+	store.put(item, nil)
+	if len(store.items) != maxStoredItems {
+		t.Error("Expected the store to stay at", maxStoredItems, "items, got", len(store.items))
+	}
+	if _, ok := store.items[first.Target()]; ok {
+		t.Error("Expected the expired item to be dropped")
+	}
+	if _, ok := store.get(item.Target()); !ok {
+		t.Error("Expected the new item to be stored")
+	}
+
+	// A full store without expired items drops a random one.
+	store.put(NewImmutableItem(bencode.String("newer")), nil)
+	if len(store.items) != maxStoredItems {
+		t.Error("Expected the store to stay at", maxStoredItems, "items, got", len(store.items))
+	}
+}
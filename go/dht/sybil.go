@@ -0,0 +1,92 @@
+package dht
+
+import (
+	"net"
+	"net/netip"
+)
+
+const DefaultMaxNodesPerIP = 1
+
+const DefaultMaxNodesPerPrefix = 2
+
+// SybilLimits limit how many entries of a routing table bucket may share an IP address, or a /24 (IPv4) or /64
+// (IPv6) prefix, so that a single host cannot fill a bucket with made-up node ids. Zero values are replaced with
+// DefaultMaxNodesPerIP and DefaultMaxNodesPerPrefix.
+type SybilLimits struct {
+	PerIP     int
+	PerPrefix int
+	// Unlimited turns the limits off, e.g. for tests with many nodes on the same host.
+	Unlimited bool
+}
+
+type RoutingEventType int
+
+const (
+	// EventIPLimit means a node was not added because its bucket already holds the maximum number of nodes with the
+	// same IP address.
+	EventIPLimit RoutingEventType = iota + 1
+	// EventPrefixLimit is the same for the maximum number of nodes with the same prefix.
+	EventPrefixLimit
+	// EventAddressChange means a node id in the routing table showed up from a new address. The change is only
+	// applied after verifying that the node no longer answers at its previous address.
+	EventAddressChange
+	EventAddressChangeAccepted
+	EventAddressChangeRejected
+)
+
+func (t RoutingEventType) String() string {
+	switch t {
+	case EventIPLimit:
+		return "ip limit"
+	case EventPrefixLimit:
+		return "prefix limit"
+	case EventAddressChange:
+		return "address change"
+	case EventAddressChangeAccepted:
+		return "address change accepted"
+	case EventAddressChangeRejected:
+		return "address change rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// RoutingEvent reports suspicious activity around the routing table, see RoutingEventType.
+type RoutingEvent struct {
+	Type RoutingEventType
+	Node NodeInfo
+	// PreviousAddress is the address in the routing table, for the address change events.
+	PreviousAddress net.UDPAddr
+}
+
+func addrFromIP(ip net.IP) netip.Addr {
+	var addr, _ = netip.AddrFromSlice(ip)
+	return addr.Unmap()
+}
+
+// checkLimits returns the event that keeps entry out of b, or 0 if it may be added. Zero limits are unlimited.
+func (b bucket) checkLimits(entry NodeInfo, maxPerIP int, maxPerPrefix int) RoutingEventType {
+	if maxPerIP <= 0 && maxPerPrefix <= 0 {
+		return 0
+	}
+
+	var ip = addrFromIP(entry.Address.IP)
+	var prefix = sourcePrefix(ip)
+	var sameIP, samePrefix = 0, 0
+	for _, existing := range b.entries {
+		var existingIP = addrFromIP(existing.Address.IP)
+		if existingIP == ip {
+			sameIP++
+		}
+		if ip.IsValid() && sourcePrefix(existingIP) == prefix {
+			samePrefix++
+		}
+	}
+
+	if maxPerIP > 0 && sameIP >= maxPerIP {
+		return EventIPLimit
+	} else if maxPerPrefix > 0 && samePrefix >= maxPerPrefix {
+		return EventPrefixLimit
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package dht
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRoutingTableSybilLimits(t *testing.T) {
+	var table = newRoutingTable(8, NodeInfo{})
+	table.maxPerIP = 1
+	table.maxPerPrefix = 2
+
+	var contact = func(id byte, ip string, port int) NodeInfo {
+		return NodeInfo{NodeId: NodeId{0xff, id}, Address: net.UDPAddr{IP: net.ParseIP(ip), Port: port}}
+	}
+
+	var cases = []struct {
+		contact NodeInfo
+		added   bool
+		event   RoutingEventType
+	}{
+		{contact(1, "1.2.3.4", 1), true, 0},
+		{contact(2, "1.2.3.4", 2), false, EventIPLimit},
+		{contact(3, "1.2.3.5", 1), true, 0},
+		{contact(4, "1.2.3.6", 1), false, EventPrefixLimit},
+		{contact(5, "2001:db8::1", 1), true, 0},
+		{contact(6, "2001:db8::2", 1), true, 0},
+		{contact(7, "2001:db8::3", 1), false, EventPrefixLimit},
+		{contact(8, "5.6.7.8", 1), true, 0},
+		{contact(1, "1.2.3.4", 1), true, 0},
+		{contact(1, "9.9.9.9", 1), false, EventAddressChange},
+	}
+
+	for i, c := range cases {
+		var result = table.addEntry(c.contact, contactResponded)
+		if result.added != c.added || result.event != c.event {
+			t.Error("Expected contact", i, "to be added:", c.added, "with event", c.event, "got", result)
+		}
+	}
+
+	var last = table.addEntry(contact(1, "9.9.9.9", 1), contactResponded)
+	if last.existing.Address.String() != "1.2.3.4:1" {
+		t.Error("Expected the existing address with the address change, got", &last.existing.Address)
+	}
+
+	table.updateAddress(NodeId{0xff, 1}, net.UDPAddr{IP: net.ParseIP("9.9.9.9"), Port: 1})
+	if result := table.addEntry(contact(1, "9.9.9.9", 1), contactResponded); !result.added {
+		t.Error("Expected the updated address to be known, got", result)
+	}
+}
+
+func TestNodeVerifiesAddressChange(t *testing.T) {
+	var events = make(chan RoutingEvent, 10)
+	var a = startTestNodeWithConfig(t, Config{OnRoutingEvent: func(event RoutingEvent) { events <- event }})
+	var b = startTestNode(t, "8000000000000000000000000000000000000001")
+	var impostor = startTestNode(t, "8000000000000000000000000000000000000001")
+
+	var expectEvent = func(expected RoutingEventType) {
+		select {
+		case event := <-events:
+			if event.Type != expected || event.Node.Address.Port != impostor.Address().Port ||
+				event.PreviousAddress.Port != b.Address().Port {
+				t.Error("Expected", expected, "event for the impostor, got", event)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("Expected", expected, "event")
+		}
+	}
+
+	if _, err := a.Ping(b.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	// b still answers at its address, so the impostor is rejected.
+	if _, err := a.Ping(impostor.Address()); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(EventAddressChange)
+	expectEvent(EventAddressChangeRejected)
+
+	// Once b is gone, the change is accepted.
+	b.Close()
+	if _, err := a.Ping(impostor.Address()); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(EventAddressChange)
+	expectEvent(EventAddressChangeAccepted)
+
+	if nodes, _ := a.routingTable.findNodeWithoutSelf(b.Id()); len(nodes) != 1 || nodes[0].Address.Port != impostor.Address().Port {
+		t.Error("Expected the node to be moved to the new address, got", nodes)
+	}
+}
@@ -0,0 +1,165 @@
+package dht
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"dhtcli/bencode"
+	"dhtcli/krpc"
+)
+
+// virtualNodes are the identities of a process that share one krpcRuntime. Each has its own routing table, while the
+// stored peers and items and the tokens are shared, since the identities are reached at the same address anyway.
+type virtualNodes struct {
+	lock  sync.RWMutex
+	nodes []*Node
+}
+
+// add adds node, unless its id is already in use.
+func (v *virtualNodes) add(node *Node) bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if slices.ContainsFunc(v.nodes, func(other *Node) bool { return other.Id() == node.Id() }) {
+		return false
+	}
+	v.nodes = append(v.nodes, node)
+	return true
+}
+
+func (v *virtualNodes) all() []*Node {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return slices.Clone(v.nodes)
+}
+
+// closestTo returns the identity whose id is closest to target.
+func (v *virtualNodes) closestTo(target NodeId) *Node {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	var closest = v.nodes[0]
+	for _, node := range v.nodes[1:] {
+		if node.Id().Distance(target).Less(closest.Id().Distance(target)) {
+			closest = node
+		}
+	}
+	return closest
+}
+
+// isOwnId reports whether id belongs to one of the identities, which must not end up in each other's routing tables.
+func (v *virtualNodes) isOwnId(id NodeId) bool {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return slices.ContainsFunc(v.nodes, func(node *Node) bool { return node.Id() == id })
+}
+
+// handleQuery passes a query to the identity closest to the id it asks about. Queries do not say which identity they
+// were sent to, but a node asking for a target contacts us because of the identity closest to it. Queries about no id
+// in particular, like ping, go to the identity the querier most recently heard from, which is the id it knows us by.
+func (v *virtualNodes) handleQuery(message *krpc.Query, source *net.UDPAddr) krpc.Message {
+	if target, ok := queryTarget(message.Arguments); ok {
+		return v.closestTo(target).handleQuery(message, source)
+	}
+	return v.knownTo(message.Arguments, *source).handleQuery(message, source)
+}
+
+// knownTo returns the identity that last got a response from source, i.e. that last sent it a query. If none did, the
+// querier can only have learned of us from others, most likely of the identity closest to its own id.
+func (v *virtualNodes) knownTo(args bencode.Dict, source net.UDPAddr) *Node {
+	v.lock.RLock()
+	var nodes = v.nodes
+	v.lock.RUnlock()
+
+	var known *Node
+	var lastResponded time.Time
+	for _, node := range nodes {
+		if responded := node.routingTable.lastResponded(source); responded.After(lastResponded) {
+			known, lastResponded = node, responded
+		}
+	}
+	if known != nil {
+		return known
+	}
+
+	if id, ok := nodeIdArgument(args); ok {
+		return v.closestTo(id)
+	}
+	return nodes[0]
+}
+
+// queryTarget returns the id a query asks about, i.e. the "target" of find_node and get, or the "info_hash" of
+// get_peers and announce_peer.
+func queryTarget(args bencode.Dict) (NodeId, bool) {
+	for _, key := range []string{"target", "info_hash"} {
+		if target, ok := args[key].(bencode.String); ok && len(target) == len(NodeId{}) {
+			return NodeId([]byte(target)), true
+		}
+	}
+	return NodeId{}, false
+}
+
+// AddVirtualNode creates another identity with the given id, which shares the socket of n but has its own routing
+// table. Incoming queries are answered by the identity closest to the id they ask about. The new node uses the
+// settings n was created with, and closing any of the identities closes all of them.
+func (n *Node) AddVirtualNode(id NodeId) (*Node, error) {
+	var thisNodeInfo = NodeInfo{NodeId: id, Address: n.Address()}
+	var node = &Node{
+		thisNodeInfo:   thisNodeInfo,
+		routingTable:   n.routingTable.emptyCopy(thisNodeInfo),
+		krpcRuntime:    n.krpcRuntime,
+		peers:          n.peers,
+		items:          n.items,
+		tokens:         n.tokens,
+		logger:         n.logger,
+		addressPolicy:  n.addressPolicy,
+		onRoutingEvent: n.onRoutingEvent,
+		virtualNodes:   n.virtualNodes,
+	}
+	if !n.virtualNodes.add(node) {
+		return nil, fmt.Errorf("adding virtual node: id %s is already in use", id)
+	}
+	return node, nil
+}
+
+// VirtualNodes returns all identities sharing the socket of n, including n itself, in the order they were added.
+func (n *Node) VirtualNodes() []*Node {
+	return n.virtualNodes.all()
+}
+
+// ClosestIdentity returns the identity sharing the socket of n whose id is closest to target. Lookups for target
+// should start from it, since its routing table knows the most about that part of the id space.
+func (n *Node) ClosestIdentity(target NodeId) *Node {
+	return n.virtualNodes.closestTo(target)
+}
+
+// ClosestNodes returns the contacts closest to target from the routing table of the closest identity, to start a
+// lookup with.
+func (n *Node) ClosestNodes(target NodeId) []NodeInfo {
+	var identity = n.ClosestIdentity(target)
+	return identity.routingTable.closestNodes(target, identity.routingTable.bucketSize)
+}
+
+// SpreadNodeIds returns count random ids that are spread evenly over the id space, for use as virtual nodes.
+func SpreadNodeIds(count int) ([]NodeId, error) {
+	var result = make([]NodeId, max(count, 0))
+	for i := range result {
+		var id, err = RandomNodeId()
+		if err != nil {
+			return nil, fmt.Errorf("generating node id: %w", err)
+		}
+
+		// Each id starts in its own slice of the id space.
+		var start = uint64(i) * (1 << 32) / uint64(count)
+		var end = uint64(i+1) * (1 << 32) / uint64(count)
+		binary.BigEndian.PutUint32(id[:4], uint32(start+uint64(binary.BigEndian.Uint32(id[:4]))%(end-start)))
+		result[i] = id
+	}
+	return result, nil
+}
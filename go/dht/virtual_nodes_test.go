@@ -0,0 +1,102 @@
+package dht
+
+import (
+	"testing"
+)
+
+func TestVirtualNodesShareSocket(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b = startTestNode(t, "4000000000000000000000000000000000000001")
+	var virtualId, _ = hexStringToNodeId("f000000000000000000000000000000000000001")
+
+	var virtual, err = a.AddVirtualNode(virtualId)
+	if err != nil || virtual.Address().Port != a.Address().Port {
+		t.Fatal("Expected virtual node on the address of a, got", virtual, "err:", err)
+	}
+	if _, err := a.AddVirtualNode(a.Id()); err == nil {
+		t.Error("Expected an id that is already in use to be rejected")
+	}
+
+	// Queries about an id go to the closest identity. Pings from a node we never queried go to the identity closest to
+	// its id.
+	if nodes, err := b.FindNode(a.Address(), virtualId); err != nil || len(nodes) != 1 || nodes[0].NodeId != virtualId {
+		t.Error("Expected the virtual node to answer for its own id, got", nodes, "err:", err)
+	}
+	if id, err := b.Ping(a.Address()); err != nil || id != a.Id() {
+		t.Error("Expected a to answer pings, got", id, "err:", err)
+	}
+
+	// Each identity learns contacts into its own table, and queries with its own id.
+	if id, err := virtual.Ping(b.Address()); err != nil || id != b.Id() {
+		t.Fatal("Expected ping from the virtual node to succeed, got", id, "err:", err)
+	}
+	// b now knows the virtual node, so its pings have to be answered with that id to keep it in b's table.
+	if id, err := b.Ping(a.Address()); err != nil || id != virtualId {
+		t.Error("Expected the virtual node that last queried b to answer its pings, got", id, "err:", err)
+	}
+	var virtualEntries = lockedEntries(virtual.routingTable)
+	if len(virtualEntries) != 1 || virtualEntries[0].NodeId != b.Id() {
+		t.Error("Expected the virtual node to know b, got", virtualEntries)
+	}
+	if aEntries := lockedEntries(a.routingTable); len(aEntries) != 1 || aEntries[0].NodeId != b.Id() {
+		t.Error("Expected a to know b from its queries, got", aEntries)
+	}
+
+	// b saw both identities at the same address.
+	var bEntries = lockedEntries(b.routingTable)
+	if len(bEntries) != 2 {
+		t.Error("Expected b to know both identities, got", bEntries)
+	}
+
+	// Our own identities do not become contacts of each other.
+	if _, err := a.FindNode(b.Address(), virtualId); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range lockedEntries(a.routingTable) {
+		if entry.NodeId == virtualId {
+			t.Error("Expected a not to add the virtual node to its routing table")
+		}
+	}
+}
+
+func TestVirtualNodesAnswerPingsFromStrangers(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var c = startTestNode(t, "f100000000000000000000000000000000000001")
+	var virtual, _ = a.AddVirtualNode(NodeId{0xf0})
+
+	if id, err := c.Ping(a.Address()); err != nil || id != virtual.Id() {
+		t.Error("Expected the identity closest to the querier to answer, got", id, "err:", err)
+	}
+}
+
+func TestClosestIdentity(t *testing.T) {
+	var a = startTestNode(t, "0000000000000000000000000000000000000001")
+	var b = startTestNode(t, "4000000000000000000000000000000000000001")
+	var virtual, _ = a.AddVirtualNode(NodeId{0xf0})
+
+	if len(a.VirtualNodes()) != 2 || a.VirtualNodes()[1] != virtual {
+		t.Error("Expected a and the virtual node, got", a.VirtualNodes())
+	}
+	if a.ClosestIdentity(NodeId{0x10}) != a || virtual.ClosestIdentity(NodeId{0x90}) != virtual {
+		t.Error("Expected the identity closest to the target")
+	}
+
+	// Lookups near the virtual node start from its routing table, which knows b.
+	virtual.Ping(b.Address())
+	if nodes := a.ClosestNodes(NodeId{0xff}); len(nodes) != 1 || nodes[0].NodeId != b.Id() {
+		t.Error("Expected contacts from the routing table of the virtual node, got", nodes)
+	}
+}
+
+func TestSpreadNodeIds(t *testing.T) {
+	var ids, err = SpreadNodeIds(4)
+	if err != nil || len(ids) != 4 {
+		t.Fatal("Expected 4 ids, got", ids, "err:", err)
+	}
+
+	for i, id := range ids {
+		if int(id[0]>>6) != i {
+			t.Error("Expected id", i, "in quarter", i, "of the id space, got", id)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package krpc
+
+import (
+	"testing"
+
+	"dhtcli/bencode"
+)
+
+func FuzzDecodeMessage(f *testing.F) {
+	f.Add([]byte("d1:eli201e8:An Errore1:t2:aa1:y1:ee"))
+	f.Add([]byte("d1:eli201ee1:t2:aa1:y1:ee"))
+	f.Add([]byte("d1:t0:1:y1:xe"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var dict, err = bencode.DecodeDict(string(data))
+		if err != nil {
+			return
+		}
+
+		message, err := DecodeMessage(dict)
+		if err != nil {
+			return
+		}
+
+		// Encoding drops keys that are not part of the message, so the first encoding is the reference.
+		var encoded = message.Encode()
+		dict, err = bencode.DecodeDict(encoded)
+		if err != nil {
+			t.Fatalf("Expected encoded message %q to decode, got %s", encoded, err)
+		}
+
+		decoded, err := DecodeMessage(dict)
+		if err != nil {
+			t.Fatalf("Expected encoded message %q to decode, got %s", encoded, err)
+		}
+
+		if decoded.Encode() != encoded || decoded.GetTransactionId() != message.GetTransactionId() {
+			t.Fatalf("Expected message %q to round-trip, got %q", encoded, decoded.Encode())
+		}
+	})
+}
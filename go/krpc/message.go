@@ -0,0 +1,182 @@
+// Package krpc contains the message types of the KRPC protocol described in BEP 5.
+package krpc
+
+import (
+	"fmt"
+
+	"dhtcli/bencode"
+)
+
+type MessageType = string
+
+const (
+	TypeQuery MessageType = "q"
+	TypeReply MessageType = "r"
+	TypeError MessageType = "e"
+)
+
+type ErrorType = int
+
+const (
+	ErrorGeneric       ErrorType = 201
+	ErrorServer        ErrorType = 202
+	ErrorProtocol      ErrorType = 203
+	ErrorUnknownMethod ErrorType = 204
+
+	// Error codes added by BEP 44
+	ErrorMessageTooBig    ErrorType = 205
+	ErrorInvalidSignature ErrorType = 206
+	ErrorSaltTooBig       ErrorType = 207
+	ErrorCasMismatch      ErrorType = 301
+	ErrorSequenceTooLow   ErrorType = 302
+)
+
+type Query struct {
+	TransactionId string
+	MethodName    string
+	Arguments     bencode.Dict
+}
+
+type Response struct {
+	TransactionId string
+	ReturnValues  bencode.Dict
+	// Version is the optional "v" key identifying the client of the responding node, typically two letters for the
+	// client and two bytes for its version.
+	Version string
+}
+
+type Error struct {
+	TransactionId string
+	Code          ErrorType
+	Message       string
+}
+
+// Message is any of *Query, *Response or *Error.
+type Message interface {
+	Encode() string
+	GetTransactionId() string
+	SetTransactionId(string)
+}
+
+func (qry *Query) Encode() string {
+	var ben = bencode.Dict{
+		"t": bencode.String(qry.TransactionId),
+		"y": bencode.String(TypeQuery),
+		"q": bencode.String(qry.MethodName),
+		"a": qry.Arguments,
+	}
+
+	return ben.Encode()
+}
+
+func (qry *Query) GetTransactionId() string {
+	return qry.TransactionId
+}
+
+func (qry *Query) SetTransactionId(id string) {
+	qry.TransactionId = id
+}
+
+func (res *Response) Encode() string {
+	var ben = bencode.Dict{
+		"t": bencode.String(res.TransactionId),
+		"y": bencode.String(TypeReply),
+		"r": res.ReturnValues,
+	}
+	if res.Version != "" {
+		ben["v"] = bencode.String(res.Version)
+	}
+
+	return ben.Encode()
+}
+
+func (res *Response) GetTransactionId() string {
+	return res.TransactionId
+}
+
+func (res *Response) SetTransactionId(id string) {
+	res.TransactionId = id
+}
+
+func (err *Error) Encode() string {
+	var ben = bencode.Dict{
+		"t": bencode.String(err.TransactionId),
+		"y": bencode.String(TypeError),
+		"e": bencode.List{bencode.Int(err.Code), bencode.String(err.Message)},
+	}
+
+	return ben.Encode()
+}
+
+func (err *Error) GetTransactionId() string {
+	return err.TransactionId
+}
+
+func (err *Error) SetTransactionId(id string) {
+	err.TransactionId = id
+}
+
+// Error makes a received KRPC error usable as a Go error.
+func (err *Error) Error() string {
+	return fmt.Sprintf("KRPC error %d: %s", err.Code, err.Message)
+}
+
+// DecodeMessage interprets an already decoded bencode dictionary as a KRPC message.
+func DecodeMessage(data bencode.Dict) (Message, error) {
+	var t, tValid = data["t"].(bencode.String)
+	var y, yValid = data["y"].(bencode.String)
+
+	if !tValid || !yValid {
+		return nil, fmt.Errorf("decoding KRPC message: transaction id or message type are missing or invalid")
+	}
+
+	switch string(y) {
+	case TypeQuery:
+		var q, qValid = data["q"].(bencode.String)
+		var a, aValid = data["a"].(bencode.Dict)
+
+		if !qValid || !aValid {
+			return nil, fmt.Errorf("decoding KRPC query: method name or arguments are missing or invalid")
+		}
+
+		return &Query{
+			TransactionId: string(t),
+			MethodName:    string(q),
+			Arguments:     a,
+		}, nil
+	case TypeReply:
+		var r, rValid = data["r"].(bencode.Dict)
+
+		if !rValid {
+			return nil, fmt.Errorf("decoding KRPC response: \"r\" is not a dictionary")
+		}
+
+		var v, _ = data["v"].(bencode.String)
+		return &Response{
+			TransactionId: string(t),
+			ReturnValues:  r,
+			Version:       string(v),
+		}, nil
+	case TypeError:
+		var e, eValid = data["e"].(bencode.List)
+
+		if !eValid || len(e) != 2 {
+			return nil, fmt.Errorf("decoding KRPC error: \"e\" is not a two-element list")
+		}
+
+		var code, codeValid = e[0].(bencode.Int)
+		var message, messageValid = e[1].(bencode.String)
+
+		if !codeValid || !messageValid {
+			return nil, fmt.Errorf("decoding KRPC error: code or message are missing or invalid")
+		}
+
+		return &Error{
+			TransactionId: string(t),
+			Code:          int(code),
+			Message:       string(message),
+		}, nil
+	default:
+		return nil, fmt.Errorf("decoding KRPC message: unknown type %q", y)
+	}
+}
@@ -0,0 +1,35 @@
+package krpc
+
+import (
+	"testing"
+
+	"dhtcli/bencode"
+)
+
+func TestKrpcError(t *testing.T) {
+	var err = Error{
+		TransactionId: "aa",
+		Code:          ErrorGeneric,
+		Message:       "An Error",
+	}
+
+	var encoded = err.Encode()
+	var expected = "d1:eli201e8:An Errore1:t2:aa1:y1:ee"
+	if encoded != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+}
+
+func TestKrpcResponseVersion(t *testing.T) {
+	var response = Response{TransactionId: "aa", ReturnValues: bencode.Dict{}, Version: "LT\x01\x02"}
+	var encoded = response.Encode()
+	if encoded != "d1:rde1:t2:aa1:v4:LT\x01\x021:y1:re" {
+		t.Error("Expected version in encoded response, got", encoded)
+	}
+
+	var dict, _ = bencode.DecodeDict(encoded)
+	var decoded, err = DecodeMessage(dict)
+	if err != nil || decoded.(*Response).Version != response.Version {
+		t.Error("Expected version to round-trip, got", decoded, "err:", err)
+	}
+}
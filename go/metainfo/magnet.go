@@ -0,0 +1,103 @@
+package metainfo
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Magnet is a magnet link as described in BEP 9, with the trackers of a torrent (tr) and peer addresses to connect to
+// directly (x.pe, as "host:port").
+type Magnet struct {
+	InfoHash    InfoHash
+	DisplayName string
+	Trackers    []string
+	Peers       []string
+}
+
+const btihPrefix = "urn:btih:"
+
+// ParseInfoHash parses an infohash in hex (40 characters) or base32 (32 characters) form, as found in magnet links.
+func ParseInfoHash(s string) (InfoHash, error) {
+	var infoHash InfoHash
+	var decoded []byte
+	var err error
+
+	switch len(s) {
+	case 2 * len(infoHash):
+		decoded, err = hex.DecodeString(s)
+	case base32.StdEncoding.EncodedLen(len(infoHash)):
+		decoded, err = base32.StdEncoding.DecodeString(strings.ToUpper(s))
+	default:
+		return infoHash, fmt.Errorf("infohash %q has invalid length %d", s, len(s))
+	}
+
+	if err != nil {
+		return infoHash, fmt.Errorf("parsing infohash %q: %w", s, err)
+	}
+
+	copy(infoHash[:], decoded)
+	return infoHash, nil
+}
+
+// ParseMagnet parses a magnet link. It must contain a BitTorrent infohash (xt=urn:btih:...).
+func ParseMagnet(uri string) (*Magnet, error) {
+	var u, err = url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parsing magnet link: %w", err)
+	} else if u.Scheme != "magnet" {
+		return nil, fmt.Errorf("parsing magnet link: unexpected scheme %q", u.Scheme)
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("parsing magnet link: %w", err)
+	}
+
+	var magnet = Magnet{
+		DisplayName: query.Get("dn"),
+		Trackers:    query["tr"],
+		Peers:       query["x.pe"],
+	}
+
+	// There may be several exact topics, e.g. also a BitTorrent v2 hash (urn:btmh:), of which we need the v1 one.
+	for _, topic := range query["xt"] {
+		if hash, ok := strings.CutPrefix(strings.ToLower(topic), btihPrefix); ok {
+			if magnet.InfoHash, err = ParseInfoHash(hash); err != nil {
+				return nil, fmt.Errorf("parsing magnet link: %w", err)
+			}
+			return &magnet, nil
+		}
+	}
+
+	return nil, fmt.Errorf("parsing magnet link: no %s exact topic", btihPrefix)
+}
+
+// String returns the magnet link, with the infohash in hex.
+func (m *Magnet) String() string {
+	var builder strings.Builder
+	builder.WriteString("magnet:?xt=" + btihPrefix + m.InfoHash.String())
+
+	if m.DisplayName != "" {
+		builder.WriteString("&dn=" + url.QueryEscape(m.DisplayName))
+	}
+	for _, tracker := range m.Trackers {
+		builder.WriteString("&tr=" + url.QueryEscape(tracker))
+	}
+	for _, peer := range m.Peers {
+		builder.WriteString("&x.pe=" + url.QueryEscape(peer))
+	}
+
+	return builder.String()
+}
+
+// Magnet returns a magnet link for the torrent, with its name and all trackers.
+func (m *MetaInfo) Magnet() *Magnet {
+	var magnet = Magnet{InfoHash: m.InfoHash, DisplayName: m.Info.Name}
+	for _, tier := range m.Trackers() {
+		magnet.Trackers = append(magnet.Trackers, tier...)
+	}
+	return &magnet
+}
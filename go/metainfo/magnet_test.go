@@ -0,0 +1,62 @@
+package metainfo
+
+import (
+	"testing"
+)
+
+func TestParseMagnet(t *testing.T) {
+	var expected, _ = ParseInfoHash("c12fe1c06bba254a9dc9f519b335aa7c1367a88a")
+
+	var hexLink = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Some+File%21" +
+		"&tr=udp%3A%2F%2Ftracker.example%3A6969&tr=http%3A%2F%2Fother.example%2Fannounce&x.pe=10.0.0.1%3A6881"
+	var magnet, err = ParseMagnet(hexLink)
+	if err != nil {
+		t.Fatal("Expected magnet link to parse, got", err)
+	}
+
+	if magnet.InfoHash != expected || magnet.DisplayName != "Some File!" {
+		t.Error("Got wrong infohash or name:", magnet.InfoHash, magnet.DisplayName)
+	}
+
+	if len(magnet.Trackers) != 2 || magnet.Trackers[0] != "udp://tracker.example:6969" {
+		t.Error("Got wrong trackers:", magnet.Trackers)
+	}
+
+	if len(magnet.Peers) != 1 || magnet.Peers[0] != "10.0.0.1:6881" {
+		t.Error("Got wrong peers:", magnet.Peers)
+	}
+
+	var base32Link = "magnet:?xt=urn:btmh:1220abcd&xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK"
+	if magnet, err := ParseMagnet(base32Link); err != nil || magnet.InfoHash != expected {
+		t.Error("Expected base32 infohash", expected, "got", magnet, "err:", err)
+	}
+}
+
+func TestMagnetString(t *testing.T) {
+	var infoHash, _ = ParseInfoHash("c12fe1c06bba254a9dc9f519b335aa7c1367a88a")
+	var magnet = Magnet{InfoHash: infoHash, DisplayName: "a b&c", Trackers: []string{"udp://t:1"}, Peers: []string{"[::1]:5"}}
+
+	var link = magnet.String()
+	var expected = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=a+b%26c&tr=udp%3A%2F%2Ft%3A1&x.pe=%5B%3A%3A1%5D%3A5"
+	if link != expected {
+		t.Error("Expected", expected, "got", link)
+	}
+
+	parsed, err := ParseMagnet(link)
+	if err != nil || parsed.DisplayName != magnet.DisplayName || parsed.Peers[0] != "[::1]:5" || parsed.InfoHash != infoHash {
+		t.Error("Expected magnet link to round-trip, got", parsed, "err:", err)
+	}
+}
+
+func TestParseMagnetInvalid(t *testing.T) {
+	for _, link := range []string{
+		"http://example.com/?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		"magnet:?dn=name",
+		"magnet:?xt=urn:btih:c12f",
+		"magnet:?xt=urn:btih:zz2fe1c06bba254a9dc9f519b335aa7c1367a88a",
+	} {
+		if _, err := ParseMagnet(link); err == nil {
+			t.Error("Expected", link, "to fail parsing")
+		}
+	}
+}
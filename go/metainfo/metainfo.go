@@ -0,0 +1,191 @@
+// Package metainfo parses .torrent files as described in BEP 3, with the multitracker extension of BEP 12.
+package metainfo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"dhtcli/bencode"
+)
+
+// InfoHash identifies a torrent in the DHT. It is the SHA-1 hash of the bencoded info dictionary.
+type InfoHash [20]byte
+
+func (h InfoHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// File is a file of a torrent. Path is relative to the torrent's name, which is the file itself for single-file
+// torrents and the top-level directory for multi-file torrents.
+type File struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+}
+
+type Info struct {
+	Name        string `bencode:"name"`
+	PieceLength int64  `bencode:"piece length"`
+	Pieces      []byte `bencode:"pieces"`
+	Private     bool   `bencode:"private,omitempty"`
+
+	// Length is set for single-file torrents, Files for multi-file torrents.
+	Length int64  `bencode:"length,omitempty"`
+	Files  []File `bencode:"files,omitempty"`
+}
+
+type MetaInfo struct {
+	Info         Info       `bencode:"info"`
+	Announce     string     `bencode:"announce,omitempty"`
+	AnnounceList [][]string `bencode:"announce-list,omitempty"`
+	Comment      string     `bencode:"comment,omitempty"`
+	CreatedBy    string     `bencode:"created by,omitempty"`
+	CreationDate int64      `bencode:"creation date,omitempty"`
+
+	// InfoHash is computed from RawInfo, the info dictionary exactly as it appears in the file. Re-encoding the
+	// decoded dictionary would change the hash of torrents that are not in canonical form, or that contain keys
+	// Info does not know about.
+	InfoHash InfoHash    `bencode:"-"`
+	RawInfo  bencode.Raw `bencode:"-"`
+}
+
+var ErrInvalidMetaInfo = errors.New("invalid metainfo")
+
+// Parse parses the content of a .torrent file.
+func Parse(data []byte) (*MetaInfo, error) {
+	var raw, _, err = bencode.ParseRaw(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metainfo: %w", err)
+	}
+
+	rawInfo, ok := raw.Lookup("info")
+	if !ok || rawInfo.Kind() != bencode.KindDict {
+		return nil, fmt.Errorf("%w: missing info dictionary", ErrInvalidMetaInfo)
+	}
+
+	var metaInfo MetaInfo
+	if err := bencode.Unmarshal(raw, &metaInfo); err != nil {
+		return nil, fmt.Errorf("parsing metainfo: %w", err)
+	}
+
+	if err := metaInfo.Info.validate(rawInfo); err != nil {
+		return nil, err
+	}
+
+	metaInfo.RawInfo = rawInfo
+	metaInfo.InfoHash = sha1.Sum(rawInfo)
+	return &metaInfo, nil
+}
+
+// Load reads and parses a .torrent file.
+func Load(name string) (*MetaInfo, error) {
+	var data, err = os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading metainfo: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// validate checks the decoded info dictionary. raw is needed to tell a length of 0 from a missing one.
+func (i *Info) validate(raw bencode.Raw) error {
+	var _, hasLength = raw.Lookup("length")
+	var _, hasFiles = raw.Lookup("files")
+
+	if err := validatePathComponent(i.Name); err != nil {
+		return fmt.Errorf("%w: name: %w", ErrInvalidMetaInfo, err)
+	} else if i.PieceLength <= 0 {
+		return fmt.Errorf("%w: piece length %d", ErrInvalidMetaInfo, i.PieceLength)
+	} else if len(i.Pieces)%sha1.Size != 0 {
+		return fmt.Errorf("%w: pieces length %d is not a multiple of %d", ErrInvalidMetaInfo, len(i.Pieces), sha1.Size)
+	} else if hasLength && hasFiles {
+		return fmt.Errorf("%w: both length and files are set", ErrInvalidMetaInfo)
+	} else if !hasLength && !hasFiles {
+		return fmt.Errorf("%w: neither length nor files are set", ErrInvalidMetaInfo)
+	} else if hasFiles && len(i.Files) == 0 {
+		return fmt.Errorf("%w: empty list of files", ErrInvalidMetaInfo)
+	} else if i.Length < 0 {
+		return fmt.Errorf("%w: negative length %d", ErrInvalidMetaInfo, i.Length)
+	}
+
+	for _, file := range i.Files {
+		if file.Length < 0 {
+			return fmt.Errorf("%w: negative length %d of file %s", ErrInvalidMetaInfo, file.Length, path.Join(file.Path...))
+		} else if len(file.Path) == 0 {
+			return fmt.Errorf("%w: file with empty path", ErrInvalidMetaInfo)
+		}
+
+		for _, component := range file.Path {
+			if err := validatePathComponent(component); err != nil {
+				return fmt.Errorf("%w: path of file %q: %w", ErrInvalidMetaInfo, file.Path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePathComponent rejects names that would not stay within the torrent's directory when used as part of a file
+// path, on any platform.
+func validatePathComponent(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty name")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid name %q", name)
+	case strings.ContainsAny(name, "/\\\x00"):
+		return fmt.Errorf("name %q contains a path separator or NUL", name)
+	}
+	return nil
+}
+
+// IsMultiFile reports whether the torrent is a directory of files rather than a single file.
+func (i *Info) IsMultiFile() bool {
+	return i.Files != nil
+}
+
+// AllFiles returns the files of the torrent. A single-file torrent has one file with an empty path.
+func (i *Info) AllFiles() []File {
+	if i.IsMultiFile() {
+		return i.Files
+	}
+	return []File{{Length: i.Length}}
+}
+
+// TotalLength returns the sum of the lengths of all files.
+func (i *Info) TotalLength() int64 {
+	var total int64
+	for _, file := range i.AllFiles() {
+		total += file.Length
+	}
+	return total
+}
+
+// NumPieces returns the number of pieces, i.e. the number of hashes in Pieces.
+func (i *Info) NumPieces() int {
+	return len(i.Pieces) / sha1.Size
+}
+
+// Trackers returns the announce URLs in tiers. It is the announce list if there is one, which takes precedence over
+// the single announce URL as per BEP 12.
+func (m *MetaInfo) Trackers() [][]string {
+	if len(m.AnnounceList) > 0 {
+		return m.AnnounceList
+	} else if m.Announce != "" {
+		return [][]string{{m.Announce}}
+	}
+	return nil
+}
+
+// Created returns the creation date, or the zero time if the torrent has none.
+func (m *MetaInfo) Created() time.Time {
+	if m.CreationDate == 0 {
+		return time.Time{}
+	}
+	return time.Unix(m.CreationDate, 0)
+}
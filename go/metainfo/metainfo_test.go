@@ -0,0 +1,122 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var pieces = strings.Repeat("a", 20) + strings.Repeat("b", 20)
+
+func TestParseSingleFile(t *testing.T) {
+	// The info dict has its keys out of order and an unknown key, so re-encoding it would give a different hash.
+	var info = "d4:name8:file.txt12:piece lengthi16384e6:lengthi20000e6:pieces40:" + pieces + "7:privatei1e6:sourcei5ee"
+	var data = "d8:announce21:udp://tracker.example13:creation datei1700000000e4:info" + info + "e"
+
+	var metaInfo, err = Parse([]byte(data))
+	if err != nil {
+		t.Fatal("Expected torrent to parse, got", err)
+	}
+
+	if metaInfo.InfoHash != sha1.Sum([]byte(info)) {
+		t.Error("Expected infohash of the raw info dict, got", metaInfo.InfoHash)
+	}
+
+	if string(metaInfo.RawInfo) != info {
+		t.Error("Expected raw info dict", info, "got", string(metaInfo.RawInfo))
+	}
+
+	if metaInfo.Info.Name != "file.txt" || metaInfo.Info.PieceLength != 16384 || !metaInfo.Info.Private {
+		t.Error("Got wrong info:", metaInfo.Info)
+	}
+
+	if metaInfo.Info.IsMultiFile() || metaInfo.Info.TotalLength() != 20000 || metaInfo.Info.NumPieces() != 2 {
+		t.Error("Expected a single file of 20000 bytes in 2 pieces, got", metaInfo.Info.AllFiles(), metaInfo.Info.NumPieces())
+	}
+
+	var trackers = metaInfo.Trackers()
+	if len(trackers) != 1 || len(trackers[0]) != 1 || trackers[0][0] != "udp://tracker.example" {
+		t.Error("Expected the announce URL as the only tracker, got", trackers)
+	}
+
+	if metaInfo.Created().Unix() != 1700000000 {
+		t.Error("Expected creation date 1700000000, got", metaInfo.Created())
+	}
+}
+
+func TestParseMultiFile(t *testing.T) {
+	var data = "d8:announce5:http:13:announce-listll5:udp:15:udp:2el5:udp:3ee" +
+		"4:infod5:filesld6:lengthi3e4:pathl1:a5:b.txteed6:lengthi4e4:pathl5:c.txteee" +
+		"4:name3:dir12:piece lengthi4e6:pieces40:" + pieces + "ee"
+
+	var metaInfo, err = Parse([]byte(data))
+	if err != nil {
+		t.Fatal("Expected torrent to parse, got", err)
+	}
+
+	var files = metaInfo.Info.AllFiles()
+	if !metaInfo.Info.IsMultiFile() || len(files) != 2 || strings.Join(files[0].Path, "/") != "a/b.txt" {
+		t.Error("Got wrong files:", files)
+	}
+
+	if metaInfo.Info.TotalLength() != 7 || metaInfo.Info.Private {
+		t.Error("Expected 7 bytes in a public torrent, got", metaInfo.Info.TotalLength(), metaInfo.Info.Private)
+	}
+
+	var trackers = metaInfo.Trackers()
+	if len(trackers) != 2 || len(trackers[0]) != 2 || trackers[1][0] != "udp:3" {
+		t.Error("Expected the announce list to take precedence, got", trackers)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	var cases = []string{
+		"d8:announce5:http:e",
+		"d4:infoi1ee",
+		"d4:infod4:name1:a12:piece lengthi1e6:pieces3:abce6:lengthi1ee",
+		"d4:infod4:name1:a12:piece lengthi0e6:pieces0:6:lengthi1eee",
+		"d4:infod12:piece lengthi1e6:pieces0:6:lengthi1eee",
+		"d4:infod4:name1:a12:piece lengthi1e6:pieces0:6:lengthi1e5:filesleee",
+	}
+
+	for _, data := range cases {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Error("Expected", data, "to fail parsing")
+		}
+	}
+
+	if _, err := Parse([]byte(cases[2])); !errors.Is(err, ErrInvalidMetaInfo) {
+		t.Error("Expected ErrInvalidMetaInfo, got", err)
+	}
+}
+
+func TestParseInvalidInfo(t *testing.T) {
+	// The entries of an info dictionary besides piece length and pieces, which are valid in all cases.
+	var cases = map[string]string{
+		"neither length nor files": "4:name1:a",
+		"length 0 and files":       "6:lengthi0e5:filesld6:lengthi1e4:pathl1:beee4:name1:a",
+		"empty list of files":      "5:filesle4:name1:a",
+		"empty path":               "5:filesld6:lengthi1e4:pathleee4:name1:a",
+		"empty path component":     "5:filesld6:lengthi1e4:pathl1:a0:eee4:name1:a",
+		"dot path component":       "5:filesld6:lengthi1e4:pathl1:.eee4:name1:a",
+		"dot dot path component":   "5:filesld6:lengthi1e4:pathl2:..1:beee4:name1:a",
+		"slash in path component":  "5:filesld6:lengthi1e4:pathl4:../beee4:name1:a",
+		"backslash in path":        "5:filesld6:lengthi1e4:pathl4:..\\beee4:name1:a",
+		"dot dot name":             "6:lengthi1e4:name2:..",
+		"slash in name":            "6:lengthi1e4:name4:/etc",
+	}
+
+	for name, entries := range cases {
+		var data = "d4:infod" + entries + "12:piece lengthi4e6:pieces40:" + pieces + "ee"
+		if _, err := Parse([]byte(data)); !errors.Is(err, ErrInvalidMetaInfo) {
+			t.Error("Expected", name, "to be invalid, got", err)
+		}
+	}
+
+	// An empty single file is fine.
+	var data = "d4:infod6:lengthi0e4:name1:a12:piece lengthi4e6:pieces0:ee"
+	if _, err := Parse([]byte(data)); err != nil {
+		t.Error("Expected a single empty file to parse, got", err)
+	}
+}